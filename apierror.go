@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultLang dipakai jika Accept-Language tidak berisi bahasa yang didukung
+const defaultLang = "en"
+
+// errorMessages memetakan code error ke pesan per bahasa. Setiap code harus ada di semua bahasa.
+var errorMessages = map[string]map[string]string{
+	"en": {
+		"invalid_request":                "invalid request body",
+		"unknown_field":                  "request body contains an unknown field",
+		"invalid_gzip":                   "request body is not valid gzip",
+		"request_too_large":              "request body is too large",
+		"credentials_required":           "username and password required",
+		"hash_failed":                    "failed to hash password",
+		"password_too_common":            "password is too common, please choose another",
+		"username_invalid_length":        "username must be 3-30 characters",
+		"username_invalid_chars":         "username may only contain letters, digits, underscore, dot and hyphen",
+		"username_invalid_edge":          "username must not start or end with underscore, dot or hyphen",
+		"username_reserved":              "username is reserved, please choose another",
+		"user_save_failed":               "failed to save user",
+		"invalid_credentials":            "invalid username or password",
+		"invalid_itinerary_request":      "invalid itinerary request",
+		"schema_violation":               "does not match the request schema",
+		"invalid_field":                  "is invalid",
+		"field_required":                 "is required",
+		"invalid_date_format":            "must be YYYY-MM-DD",
+		"invalid_calendar_date":          "is not a valid calendar date",
+		"end_date_before_start_date":     "must not be before start_date",
+		"unknown_preference":             "is not a known preference, see GET /itinerary/options",
+		"preference_weight_out_of_range": "must be between 0 and 10",
+		"json_parse_failed":              "failed to parse JSON",
+		"marshal_failed":                 "Error marshaling JSON",
+		"upstream_request_failed":        "Error creating request",
+		"upstream_unreachable":           "Error sending request",
+		"upstream_read_failed":           "Error reading response",
+		"upstream_response_too_large":    "itinerary generator returned an oversized response",
+		"upstream_unavailable":           "itinerary generator is temporarily unavailable, please retry shortly",
+		"not_acceptable":                 "only application/json responses are supported",
+		"maintenance":                    "service under maintenance, please try again later",
+		"request_timeout":                "request timeout",
+		"rate_limited":                   "too many requests, please retry later",
+		"database_unavailable":           "database unavailable",
+		"too_busy":                       "too many itinerary requests in progress, please retry shortly",
+	},
+	"id": {
+		"invalid_request":                "body request tidak valid",
+		"unknown_field":                  "body request berisi field yang tidak dikenal",
+		"invalid_gzip":                   "body request bukan gzip yang valid",
+		"request_too_large":              "body request terlalu besar",
+		"credentials_required":           "username dan password wajib diisi",
+		"hash_failed":                    "gagal memproses password",
+		"password_too_common":            "password terlalu umum, silakan pilih yang lain",
+		"username_invalid_length":        "username harus 3-30 karakter",
+		"username_invalid_chars":         "username hanya boleh berisi huruf, angka, underscore, titik, dan hyphen",
+		"username_invalid_edge":          "username tidak boleh diawali atau diakhiri underscore, titik, atau hyphen",
+		"username_reserved":              "username sudah dicadangkan, silakan pilih yang lain",
+		"user_save_failed":               "gagal menyimpan user",
+		"invalid_credentials":            "username atau password salah",
+		"invalid_itinerary_request":      "request itinerary tidak valid",
+		"schema_violation":               "tidak sesuai schema request",
+		"invalid_field":                  "tidak valid",
+		"field_required":                 "wajib diisi",
+		"invalid_date_format":            "harus berformat YYYY-MM-DD",
+		"invalid_calendar_date":          "bukan tanggal kalender yang valid",
+		"end_date_before_start_date":     "tidak boleh sebelum start_date",
+		"unknown_preference":             "bukan preferensi yang dikenal, lihat GET /itinerary/options",
+		"preference_weight_out_of_range": "harus antara 0 dan 10",
+		"json_parse_failed":              "gagal mem-parsing JSON",
+		"marshal_failed":                 "gagal menyusun JSON",
+		"upstream_request_failed":        "gagal membuat request ke generator",
+		"upstream_unreachable":           "gagal menghubungi generator itinerary",
+		"upstream_read_failed":           "gagal membaca response generator",
+		"upstream_response_too_large":    "response generator itinerary terlalu besar",
+		"upstream_unavailable":           "generator itinerary sedang tidak tersedia, silakan coba lagi sebentar",
+		"not_acceptable":                 "hanya response application/json yang didukung",
+		"maintenance":                    "layanan sedang dalam pemeliharaan, silakan coba lagi nanti",
+		"request_timeout":                "request melebihi batas waktu",
+		"rate_limited":                   "terlalu banyak request, silakan coba lagi nanti",
+		"database_unavailable":           "database tidak tersedia",
+		"too_busy":                       "terlalu banyak request itinerary yang sedang diproses, silakan coba lagi sebentar",
+	},
+}
+
+// APIError adalah error untuk client: status HTTP dan code stabil yang dipetakan ke pesan
+// terlokalisasi. Err (opsional) adalah penyebab asli dan tidak pernah dikirim ke client.
+type APIError struct {
+	Status int
+	Code   string
+	Err    error
+}
+
+func (e *APIError) Error() string {
+	if e.Err == nil {
+		return e.Code
+	}
+	return e.Code + ": " + e.Err.Error()
+}
+
+func (e *APIError) Unwrap() error { return e.Err }
+
+// respondError mengirim {"error": <pesan terlokalisasi>, "code": <code>} dan meng-abort chain
+func respondError(c *gin.Context, status int, code string) {
+	respondErrorWith(c, status, code, nil)
+}
+
+// respondErrorWith seperti respondError dengan field tambahan (mis. "detail" atau "errors")
+func respondErrorWith(c *gin.Context, status int, code string, extra gin.H) {
+	body := gin.H{"error": localizedMessage(c, code), "code": code}
+	for k, v := range extra {
+		body[k] = v
+	}
+	c.AbortWithStatusJSON(status, body)
+}
+
+// localizedMessage mengambil pesan untuk code sesuai Accept-Language request
+func localizedMessage(c *gin.Context, code string) string {
+	if msg, ok := errorMessages[requestLang(c)][code]; ok {
+		return msg
+	}
+	if msg, ok := errorMessages[defaultLang][code]; ok {
+		return msg
+	}
+	return code
+}
+
+// requestLang memilih bahasa yang didukung dengan q tertinggi dari Accept-Language
+func requestLang(c *gin.Context) string {
+	best, bestQ := defaultLang, 0.0
+	for _, part := range strings.Split(c.GetHeader("Accept-Language"), ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.ToLower(strings.TrimSpace(fields[0]))
+		lang, _, _ := strings.Cut(tag, "-")
+		if _, ok := errorMessages[lang]; !ok {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			if k, v, ok := strings.Cut(strings.TrimSpace(param), "="); ok && k == "q" {
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					q = f
+				}
+			}
+		}
+		if q > bestQ {
+			best, bestQ = lang, q
+		}
+	}
+	return best
+}
+
+// statusClientClosedRequest (nginx 499) dicatat untuk request yang ditinggal client
+const statusClientClosedRequest = 499
+
+// isClientGone cek apakah err terjadi karena client disconnect (context request dibatalkan)
+func isClientGone(err error) bool {
+	return errors.Is(err, context.Canceled)
+}
+
+// abortIfClientGone menghentikan request tanpa response error jika client sudah disconnect,
+// supaya tidak tercatat sebagai 500. Mengembalikan true jika request dihentikan.
+func abortIfClientGone(c *gin.Context, err error) bool {
+	if !isClientGone(err) {
+		return false
+	}
+	log.Printf("client gone: %s %s", c.Request.Method, c.Request.URL.Path)
+	c.AbortWithStatus(statusClientClosedRequest)
+	return true
+}
+
+// respondLoggedError mencatat err lengkap di server (beserta request id) lalu mengirim error publik
+// code ke client. Bungkus err dengan konteks sebelum dipanggil, e.g. fmt.Errorf("signup insert: %w", err).
+func respondLoggedError(c *gin.Context, status int, code string, err error) {
+	log.Printf("request_id=%s trace_id=%s %s %s -> %d %s: %v", requestID(c), traceID(c), c.Request.Method, c.Request.URL.Path, status, code, err)
+	respondError(c, status, code)
+}
+
+// requestID mengambil request id yang di-set requestIDMiddleware, atau "-" jika tidak ada
+func requestID(c *gin.Context) string {
+	if id := c.GetString(requestIDKey); id != "" {
+		return id
+	}
+	return "-"
+}
+
+// traceID mengambil trace id Cloud Trace dari request, atau "-" jika tidak ada
+func traceID(c *gin.Context) string {
+	if id := c.GetString(traceIDKey); id != "" {
+		return id
+	}
+	return "-"
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// errCircuitOpen dikembalikan allow saat breaker sedang open
+var errCircuitOpen = errors.New("circuit breaker is open")
+
+// State circuit breaker
+const (
+	breakerClosed   = "closed"
+	breakerOpen     = "open"
+	breakerHalfOpen = "half_open"
+)
+
+// circuitBreaker menghentikan panggilan ke dependency yang sedang gagal. Setelah threshold
+// kegagalan berturut-turut breaker open dan menolak panggilan selama cooldown, lalu half-open:
+// satu panggilan percobaan dibiarkan lewat, sukses menutup breaker, gagal membukanya lagi.
+type circuitBreaker struct {
+	name      string
+	threshold int // 0 menonaktifkan breaker
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	probing  bool // ada panggilan percobaan yang sedang berjalan saat half-open
+}
+
+func newCircuitBreaker(name string, threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{name: name, threshold: threshold, cooldown: cooldown, state: breakerClosed}
+}
+
+// allow mengembalikan errCircuitOpen jika panggilan harus ditolak. Setiap allow yang sukses
+// wajib diikuti record.
+func (b *circuitBreaker) allow() error {
+	if b.threshold <= 0 {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == breakerOpen && time.Since(b.openedAt) >= b.cooldown {
+		b.setState(breakerHalfOpen)
+	}
+	switch b.state {
+	case breakerOpen:
+		return errCircuitOpen
+	case breakerHalfOpen:
+		if b.probing {
+			return errCircuitOpen
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// record mencatat hasil panggilan yang diizinkan allow
+func (b *circuitBreaker) record(failed bool) {
+	if b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if !failed {
+		b.failures = 0
+		if b.state != breakerClosed {
+			b.setState(breakerClosed)
+		}
+		return
+	}
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= b.threshold {
+		b.openedAt = time.Now()
+		if b.state != breakerOpen {
+			b.setState(breakerOpen)
+		}
+	}
+}
+
+// currentState mengembalikan state breaker saat ini (closed, open, half_open)
+func (b *circuitBreaker) currentState() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// setState mengganti state dan mencatat transisinya; mu harus sudah dipegang
+func (b *circuitBreaker) setState(s string) {
+	log.Printf("circuit breaker %s: %s -> %s (consecutive failures: %d)", b.name, b.state, s, b.failures)
+	b.state = s
+}
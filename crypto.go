@@ -0,0 +1,129 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// errEncryptionNotConfigured dikembalikan encrypt/decrypt jika DATA_ENCRYPTION_KEY tidak di-set
+var errEncryptionNotConfigured = errors.New("data encryption key not configured")
+
+// dataKeys berisi key AES-256-GCM per versi untuk enkripsi kolom sensitif; nil jika tidak dikonfigurasi
+var dataKeys *dataKeyring
+
+// dataKeyring: enkripsi selalu memakai key versi current, dekripsi memakai versi yang tertulis di ciphertext
+type dataKeyring struct {
+	current int
+	keys    map[int]cipher.AEAD
+}
+
+// loadDataKeys membaca key dari env:
+//   - DATA_ENCRYPTION_KEY: key aktif, base64 32 byte
+//   - DATA_ENCRYPTION_KEY_VERSION: versi key aktif (default 1)
+//   - DATA_ENCRYPTION_OLD_KEYS: key lama untuk dekripsi saat rotasi, e.g. "1=<base64>,2=<base64>"
+//
+// Mengembalikan nil tanpa error jika DATA_ENCRYPTION_KEY kosong.
+func loadDataKeys() (*dataKeyring, error) {
+	raw := os.Getenv("DATA_ENCRYPTION_KEY")
+	if raw == "" {
+		return nil, nil
+	}
+
+	kr := &dataKeyring{current: 1, keys: map[int]cipher.AEAD{}}
+	if v := os.Getenv("DATA_ENCRYPTION_KEY_VERSION"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid DATA_ENCRYPTION_KEY_VERSION %q", v)
+		}
+		kr.current = n
+	}
+	if err := kr.add(kr.current, raw); err != nil {
+		return nil, fmt.Errorf("DATA_ENCRYPTION_KEY: %w", err)
+	}
+
+	if old := os.Getenv("DATA_ENCRYPTION_OLD_KEYS"); old != "" {
+		for _, entry := range strings.Split(old, ",") {
+			v, key, ok := strings.Cut(strings.TrimSpace(entry), "=")
+			n, err := strconv.Atoi(v)
+			if !ok || err != nil || n <= 0 {
+				return nil, errors.New("invalid DATA_ENCRYPTION_OLD_KEYS entry, expected <version>=<base64 key>")
+			}
+			if n == kr.current {
+				return nil, fmt.Errorf("DATA_ENCRYPTION_OLD_KEYS must not contain the active version %d", n)
+			}
+			if err := kr.add(n, key); err != nil {
+				return nil, fmt.Errorf("DATA_ENCRYPTION_OLD_KEYS version %d: %w", n, err)
+			}
+		}
+	}
+	return kr, nil
+}
+
+// add mendaftarkan key base64 (harus 32 byte) untuk versi tertentu
+func (kr *dataKeyring) add(version int, b64 string) error {
+	key, err := base64.StdEncoding.DecodeString(b64)
+	if err != nil {
+		return errors.New("key must be base64")
+	}
+	if len(key) != 32 {
+		return fmt.Errorf("key must be 32 bytes, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+	kr.keys[version] = aead
+	return nil
+}
+
+// encrypt mengenkripsi plaintext dengan key aktif. Format hasil: "v<versi>:<base64(nonce||ciphertext)>"
+func encrypt(plaintext []byte) (string, error) {
+	if dataKeys == nil {
+		return "", errEncryptionNotConfigured
+	}
+	aead := dataKeys.keys[dataKeys.current]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, nil)
+	return fmt.Sprintf("v%d:%s", dataKeys.current, base64.StdEncoding.EncodeToString(sealed)), nil
+}
+
+// decrypt membuka hasil encrypt memakai key sesuai versi di prefix ciphertext
+func decrypt(ciphertext string) ([]byte, error) {
+	if dataKeys == nil {
+		return nil, errEncryptionNotConfigured
+	}
+	prefix, payload, ok := strings.Cut(ciphertext, ":")
+	version, err := strconv.Atoi(strings.TrimPrefix(prefix, "v"))
+	if !ok || !strings.HasPrefix(prefix, "v") || err != nil {
+		return nil, errors.New("invalid ciphertext format")
+	}
+	aead, ok := dataKeys.keys[version]
+	if !ok {
+		return nil, fmt.Errorf("unknown encryption key version %d", version)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return nil, errors.New("invalid ciphertext format")
+	}
+	nonce, data := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, data, nil)
+}
+
+// needsReencrypt cek apakah ciphertext dibuat dengan key lama dan perlu dienkripsi ulang
+func needsReencrypt(ciphertext string) bool {
+	return dataKeys != nil && !strings.HasPrefix(ciphertext, fmt.Sprintf("v%d:", dataKeys.current))
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// testKey membuat key base64 32 byte yang deterministik dari satu byte
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, 32))
+}
+
+// useDataKeys memuat keyring dari env yang diberikan dan memasangnya sebagai dataKeys selama test
+func useDataKeys(t *testing.T, env map[string]string) {
+	t.Helper()
+	for _, k := range []string{"DATA_ENCRYPTION_KEY", "DATA_ENCRYPTION_KEY_VERSION", "DATA_ENCRYPTION_OLD_KEYS"} {
+		t.Setenv(k, env[k])
+	}
+	kr, err := loadDataKeys()
+	if err != nil {
+		t.Fatalf("loadDataKeys: %v", err)
+	}
+	prev := dataKeys
+	dataKeys = kr
+	t.Cleanup(func() { dataKeys = prev })
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	useDataKeys(t, map[string]string{"DATA_ENCRYPTION_KEY": testKey(1)})
+
+	plaintext := []byte("totp-secret-JBSWY3DPEHPK3PXP")
+	ct, err := encrypt(plaintext)
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	if !strings.HasPrefix(ct, "v1:") {
+		t.Errorf("ciphertext %q: want prefix v1:", ct)
+	}
+	if strings.Contains(ct, string(plaintext)) {
+		t.Error("ciphertext contains plaintext")
+	}
+
+	got, err := decrypt(ct)
+	if err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Errorf("decrypt = %q, want %q", got, plaintext)
+	}
+
+	// Nonce acak: plaintext sama menghasilkan ciphertext berbeda
+	ct2, err := encrypt(plaintext)
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	if ct == ct2 {
+		t.Error("two encryptions of the same plaintext are identical")
+	}
+}
+
+func TestDecryptRejectsTampering(t *testing.T) {
+	useDataKeys(t, map[string]string{"DATA_ENCRYPTION_KEY": testKey(1)})
+
+	ct, err := encrypt([]byte("secret"))
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	prefix, payload, _ := strings.Cut(ct, ":")
+	sealed, _ := base64.StdEncoding.DecodeString(payload)
+	sealed[len(sealed)-1] ^= 0x01
+	flipped := prefix + ":" + base64.StdEncoding.EncodeToString(sealed)
+
+	for name, in := range map[string]string{
+		"flipped byte":    flipped,
+		"truncated":       ct[:len(ct)-8],
+		"unknown version": "v9:" + payload,
+		"no prefix":       payload,
+		"not base64":      "v1:!!!",
+		"too short":       "v1:" + base64.StdEncoding.EncodeToString([]byte("short")),
+	} {
+		if _, err := decrypt(in); err == nil {
+			t.Errorf("%s: decrypt succeeded, want error", name)
+		}
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	useDataKeys(t, map[string]string{"DATA_ENCRYPTION_KEY": testKey(1)})
+	oldCT, err := encrypt([]byte("secret"))
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+
+	// Rotasi: key baru versi 2 aktif, key versi 1 tetap bisa dekripsi
+	useDataKeys(t, map[string]string{
+		"DATA_ENCRYPTION_KEY":         testKey(2),
+		"DATA_ENCRYPTION_KEY_VERSION": "2",
+		"DATA_ENCRYPTION_OLD_KEYS":    "1=" + testKey(1),
+	})
+	got, err := decrypt(oldCT)
+	if err != nil || string(got) != "secret" {
+		t.Fatalf("decrypt old ciphertext = %q, %v; want secret", got, err)
+	}
+	if !needsReencrypt(oldCT) {
+		t.Error("needsReencrypt(old ciphertext) = false, want true")
+	}
+
+	newCT, err := encrypt(got)
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	if !strings.HasPrefix(newCT, "v2:") || needsReencrypt(newCT) {
+		t.Errorf("re-encrypted ciphertext %q should use v2 and not need re-encryption", newCT)
+	}
+
+	// Setelah key lama dibuang, ciphertext lama tidak bisa dibuka lagi
+	useDataKeys(t, map[string]string{
+		"DATA_ENCRYPTION_KEY":         testKey(2),
+		"DATA_ENCRYPTION_KEY_VERSION": "2",
+	})
+	if _, err := decrypt(oldCT); err == nil {
+		t.Error("decrypt with retired key succeeded, want error")
+	}
+}
+
+func TestLoadDataKeysInvalid(t *testing.T) {
+	tests := map[string]map[string]string{
+		"not base64":       {"DATA_ENCRYPTION_KEY": "not-base64!"},
+		"wrong length":     {"DATA_ENCRYPTION_KEY": base64.StdEncoding.EncodeToString([]byte("short"))},
+		"bad version":      {"DATA_ENCRYPTION_KEY": testKey(1), "DATA_ENCRYPTION_KEY_VERSION": "0"},
+		"bad old entry":    {"DATA_ENCRYPTION_KEY": testKey(1), "DATA_ENCRYPTION_OLD_KEYS": "abc"},
+		"old is active":    {"DATA_ENCRYPTION_KEY": testKey(1), "DATA_ENCRYPTION_OLD_KEYS": "1=" + testKey(2)},
+		"old wrong length": {"DATA_ENCRYPTION_KEY": testKey(1), "DATA_ENCRYPTION_KEY_VERSION": "2", "DATA_ENCRYPTION_OLD_KEYS": "1=AAAA"},
+	}
+	for name, env := range tests {
+		for _, k := range []string{"DATA_ENCRYPTION_KEY", "DATA_ENCRYPTION_KEY_VERSION", "DATA_ENCRYPTION_OLD_KEYS"} {
+			t.Setenv(k, env[k])
+		}
+		if _, err := loadDataKeys(); err == nil {
+			t.Errorf("%s: loadDataKeys succeeded, want error", name)
+		}
+	}
+}
+
+func TestEncryptionNotConfigured(t *testing.T) {
+	useDataKeys(t, map[string]string{})
+	if _, err := encrypt([]byte("x")); !errors.Is(err, errEncryptionNotConfigured) {
+		t.Errorf("encrypt error = %v, want errEncryptionNotConfigured", err)
+	}
+	if _, err := decrypt("v1:AAAA"); !errors.Is(err, errEncryptionNotConfigured) {
+		t.Errorf("decrypt error = %v, want errEncryptionNotConfigured", err)
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// maxTxAttempts membatasi berapa kali transaksi diulang saat serialization failure
+const maxTxAttempts = 3
+
+// withTx menjalankan fn di dalam satu transaksi: commit jika fn sukses, rollback jika error.
+// Transaksi diulang dari awal bila Postgres melaporkan serialization failure (SQLSTATE 40001),
+// jadi fn harus aman dijalankan lebih dari sekali.
+func withTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	var err error
+	for attempt := 1; attempt <= maxTxAttempts; attempt++ {
+		err = runTx(ctx, db, fn)
+		if err == nil || !isSerializationFailure(err) {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	return fmt.Errorf("transaction failed after %d attempts: %w", maxTxAttempts, err)
+}
+
+// runTx menjalankan satu percobaan transaksi
+func runTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// isSerializationFailure cek apakah err adalah serialization failure dari Postgres
+func isSerializationFailure(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "40001"
+}
+
+// slowQueryThreshold: query lebih lama dari ini di-log sebagai warning (SLOW_QUERY_MS)
+var slowQueryThreshold = 500 * time.Millisecond
+
+// logSlowQuery mencatat query yang melewati slowQueryThreshold
+func logSlowQuery(name string, start time.Time) {
+	if d := time.Since(start); d > slowQueryThreshold {
+		log.Printf("⚠️ slow query %s took %s", name, d.Round(time.Millisecond))
+	}
+}
+
+// Timeout per jenis operasi (DB_READ_TIMEOUT / DB_WRITE_TIMEOUT); write diberi ruang lebih.
+// Deadline request tetap berlaku jika lebih cepat.
+var (
+	dbReadTimeout  = 5 * time.Second
+	dbWriteTimeout = 15 * time.Second
+)
+
+// execContext menjalankan statement di db dan mencatat jika lambat; name dipakai di log.
+// Diulang sekali hanya jika statement pasti belum terkirim ke server (aman untuk write).
+func execContext(ctx context.Context, name, query string, args ...interface{}) (sql.Result, error) {
+	defer logSlowQuery(name, time.Now())
+	ctx, cancel := context.WithTimeout(ctx, dbWriteTimeout)
+	defer cancel()
+
+	res, err := db.ExecContext(ctx, query, args...)
+	if err != nil && isSafeToRetry(err) && ctx.Err() == nil {
+		log.Printf("query %s: retrying after connection error: %v", name, err)
+		res, err = db.ExecContext(ctx, query, args...)
+	}
+	return res, err
+}
+
+// queryRowContext menjalankan query satu baris di db dan mencatat jika lambat
+func queryRowContext(ctx context.Context, name, query string, args ...interface{}) *timedRow {
+	defer logSlowQuery(name, time.Now())
+	return queryRowRetry(ctx, db, name, query, args...)
+}
+
+// replicaQueryRowContext seperti queryRowContext tapi ke read replica. Hanya untuk SELECT
+// yang toleran terhadap replication lag; jangan untuk auth (signin harus melihat signup terbaru).
+func replicaQueryRowContext(ctx context.Context, name, query string, args ...interface{}) *timedRow {
+	defer logSlowQuery(name, time.Now())
+	return queryRowRetry(ctx, dbReplica, name, query, args...)
+}
+
+// timedRow membungkus *sql.Row yang context-nya punya read timeout; context baru dilepas
+// setelah Scan, jadi Scan wajib dipanggil
+type timedRow struct {
+	row    *sql.Row
+	cancel context.CancelFunc
+}
+
+func (r *timedRow) Scan(dest ...interface{}) error {
+	defer r.cancel()
+	return r.row.Scan(dest...)
+}
+
+// queryRowRetry mengulang query read-only sekali pada koneksi lain jika koneksi putus
+// (mis. connection reset setelah failover Cloud SQL)
+func queryRowRetry(ctx context.Context, conn *sql.DB, name, query string, args ...interface{}) *timedRow {
+	ctx, cancel := context.WithTimeout(ctx, dbReadTimeout)
+	row := conn.QueryRowContext(ctx, query, args...)
+	if err := row.Err(); err != nil && isConnError(err) && ctx.Err() == nil {
+		log.Printf("query %s: retrying after connection error: %v", name, err)
+		row = conn.QueryRowContext(ctx, query, args...)
+	}
+	return &timedRow{row: row, cancel: cancel}
+}
+
+// isSafeToRetry: error koneksi yang terjadi sebelum query terkirim ke server
+func isSafeToRetry(err error) bool {
+	return errors.Is(err, driver.ErrBadConn) || pgconn.SafeToRetry(err)
+}
+
+// isConnError: error karena koneksi putus/rusak, bukan karena query-nya.
+// Untuk write, pakai isSafeToRetry karena statement mungkin sudah dieksekusi.
+func isConnError(err error) bool {
+	return isSafeToRetry(err) ||
+		errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE)
+}
+
+// warmupDB membuka n koneksi secara paralel lalu mengembalikannya ke pool, supaya
+// request pertama setelah cold start tidak perlu menunggu dial ke Cloud SQL
+func warmupDB(ctx context.Context, db *sql.DB, n int) {
+	if n <= 0 {
+		return
+	}
+	// Default database/sql hanya menyimpan 2 koneksi idle; sisanya akan langsung ditutup
+	if n > 2 {
+		db.SetMaxIdleConns(n)
+	}
+
+	start := time.Now()
+	conns := make([]*sql.Conn, n)
+	var wg sync.WaitGroup
+	for i := range conns {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			conn, err := db.Conn(ctx)
+			if err != nil {
+				log.Printf("db warmup: %v", err)
+				return
+			}
+			var one int
+			if err := conn.QueryRowContext(ctx, "SELECT 1").Scan(&one); err != nil {
+				log.Printf("db warmup: %v", err)
+			}
+			conns[i] = conn
+		}(i)
+	}
+	wg.Wait()
+
+	// Semua koneksi dipegang bersamaan di atas, jadi pool benar-benar membuka n koneksi
+	warmed := 0
+	for _, conn := range conns {
+		if conn != nil {
+			conn.Close()
+			warmed++
+		}
+	}
+	log.Printf("✔️ DB pool warmed up with %d connections in %s", warmed, time.Since(start).Round(time.Millisecond))
+}
+
+// dbHealthy di-update oleh startDBPinger; dipakai requireDB untuk menolak request saat DB down
+var dbHealthy atomic.Bool
+
+// dbPingFailureThreshold: jumlah ping gagal berturut-turut sebelum DB dianggap tidak sehat,
+// supaya satu ping yang lambat tidak langsung menolak semua request
+const dbPingFailureThreshold = 2
+
+// startDBPinger menge-ping db setiap interval di background dan meng-update dbHealthy
+func startDBPinger(ctx context.Context, conn *sql.DB, interval time.Duration) {
+	dbHealthy.Store(conn != nil)
+	if conn == nil {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		failures := 0
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			pingCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+			err := conn.PingContext(pingCtx)
+			cancel()
+
+			if err == nil {
+				if !dbHealthy.Swap(true) {
+					log.Println("✔️ Database reachable again")
+				}
+				failures = 0
+				continue
+			}
+			failures++
+			if failures >= dbPingFailureThreshold && dbHealthy.Swap(false) {
+				log.Printf("⚠️ Database unhealthy after %d failed pings: %v", failures, err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// fakeTxDriver adalah driver database/sql minimal untuk menguji withTx tanpa Postgres:
+// hanya mencatat begin/commit/rollback, dan commitErrs dikembalikan berurutan oleh Commit
+type fakeTxDriver struct {
+	mu         sync.Mutex
+	begins     int
+	commits    int
+	rollbacks  int
+	commitErrs []error
+}
+
+func (d *fakeTxDriver) Open(string) (driver.Conn, error) { return &fakeTxConn{d: d}, nil }
+
+type fakeTxConn struct{ d *fakeTxDriver }
+
+func (c *fakeTxConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("fakeTxConn: statements not supported")
+}
+func (c *fakeTxConn) Close() error { return nil }
+func (c *fakeTxConn) Begin() (driver.Tx, error) {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	c.d.begins++
+	return &fakeTx{d: c.d}, nil
+}
+
+type fakeTx struct{ d *fakeTxDriver }
+
+func (tx *fakeTx) Commit() error {
+	tx.d.mu.Lock()
+	defer tx.d.mu.Unlock()
+	if len(tx.d.commitErrs) > 0 {
+		err := tx.d.commitErrs[0]
+		tx.d.commitErrs = tx.d.commitErrs[1:]
+		if err != nil {
+			return err
+		}
+	}
+	tx.d.commits++
+	return nil
+}
+
+func (tx *fakeTx) Rollback() error {
+	tx.d.mu.Lock()
+	defer tx.d.mu.Unlock()
+	tx.d.rollbacks++
+	return nil
+}
+
+// openFakeTxDB membuka *sql.DB di atas fakeTxDriver baru
+func openFakeTxDB(t *testing.T, commitErrs ...error) (*sql.DB, *fakeTxDriver) {
+	t.Helper()
+	d := &fakeTxDriver{commitErrs: commitErrs}
+	conn := sql.OpenDB(fakeConnector{d})
+	t.Cleanup(func() { conn.Close() })
+	return conn, d
+}
+
+type fakeConnector struct{ d *fakeTxDriver }
+
+func (c fakeConnector) Connect(context.Context) (driver.Conn, error) { return c.d.Open("") }
+func (c fakeConnector) Driver() driver.Driver                        { return c.d }
+
+var errSerialization = &pgconn.PgError{Code: "40001", Message: "could not serialize access"}
+
+func TestWithTxCommits(t *testing.T) {
+	conn, d := openFakeTxDB(t)
+	calls := 0
+	err := withTx(context.Background(), conn, func(tx *sql.Tx) error {
+		calls++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("withTx: %v", err)
+	}
+	if calls != 1 || d.commits != 1 || d.rollbacks != 0 {
+		t.Errorf("calls=%d commits=%d rollbacks=%d, want 1/1/0", calls, d.commits, d.rollbacks)
+	}
+}
+
+func TestWithTxRollsBackOnError(t *testing.T) {
+	conn, d := openFakeTxDB(t)
+	want := errors.New("insert failed")
+	calls := 0
+	err := withTx(context.Background(), conn, func(tx *sql.Tx) error {
+		calls++
+		return want
+	})
+	if !errors.Is(err, want) {
+		t.Fatalf("withTx error = %v, want %v", err, want)
+	}
+	// Error biasa tidak diulang
+	if calls != 1 || d.commits != 0 || d.rollbacks != 1 {
+		t.Errorf("calls=%d commits=%d rollbacks=%d, want 1/0/1", calls, d.commits, d.rollbacks)
+	}
+}
+
+func TestWithTxRetriesSerializationFailure(t *testing.T) {
+	conn, d := openFakeTxDB(t)
+	calls := 0
+	err := withTx(context.Background(), conn, func(tx *sql.Tx) error {
+		calls++
+		if calls < maxTxAttempts {
+			return errSerialization
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("withTx: %v", err)
+	}
+	if calls != maxTxAttempts || d.commits != 1 || d.rollbacks != maxTxAttempts-1 {
+		t.Errorf("calls=%d commits=%d rollbacks=%d, want %d/1/%d", calls, d.commits, d.rollbacks, maxTxAttempts, maxTxAttempts-1)
+	}
+}
+
+func TestWithTxRetriesSerializationFailureOnCommit(t *testing.T) {
+	conn, d := openFakeTxDB(t, errSerialization)
+	calls := 0
+	err := withTx(context.Background(), conn, func(tx *sql.Tx) error {
+		calls++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("withTx: %v", err)
+	}
+	if calls != 2 || d.commits != 1 {
+		t.Errorf("calls=%d commits=%d, want 2/1", calls, d.commits)
+	}
+}
+
+func TestWithTxGivesUpAfterMaxAttempts(t *testing.T) {
+	conn, d := openFakeTxDB(t)
+	calls := 0
+	err := withTx(context.Background(), conn, func(tx *sql.Tx) error {
+		calls++
+		return errSerialization
+	})
+	if !isSerializationFailure(err) {
+		t.Fatalf("withTx error = %v, want wrapped serialization failure", err)
+	}
+	if calls != maxTxAttempts || d.commits != 0 || d.rollbacks != maxTxAttempts {
+		t.Errorf("calls=%d commits=%d rollbacks=%d, want %d/0/%d", calls, d.commits, d.rollbacks, maxTxAttempts, maxTxAttempts)
+	}
+}
+
+func TestWithTxStopsWhenContextDone(t *testing.T) {
+	conn, _ := openFakeTxDB(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	err := withTx(ctx, conn, func(tx *sql.Tx) error {
+		calls++
+		cancel()
+		return errSerialization
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("withTx error = %v, want context.Canceled", err)
+	}
+	if calls != 1 {
+		t.Errorf("calls=%d, want 1", calls)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// envDuration membaca durasi (format time.ParseDuration, e.g. "30s") dari env, atau def jika kosong
+func envDuration(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		log.Fatalf("invalid %s: %q", key, v)
+	}
+	return d
+}
+
+// envInt membaca integer dari env, atau def jika kosong
+func envInt(key string, def int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Fatalf("invalid %s: %q", key, v)
+	}
+	return n
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// checkResult adalah hasil satu pemeriksaan dependency
+type checkResult struct {
+	Status     string `json:"status"` // "ok" atau "error"
+	DurationMS int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+// runCheck menjalankan fn dengan timeout dan mengukur durasinya
+func runCheck(ctx context.Context, timeout time.Duration, fn func(ctx context.Context) error) checkResult {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := fn(ctx)
+	res := checkResult{Status: "ok", DurationMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		res.Status = "error"
+		res.Error = err.Error()
+	}
+	return res
+}
+
+// checkDBRoundtrip menulis lalu membaca satu baris di temp table dalam transaksi yang
+// selalu di-rollback, jadi tidak ada data yang tersimpan
+func checkDBRoundtrip(ctx context.Context, conn *sql.DB) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `CREATE TEMP TABLE health_probe (v int) ON COMMIT DROP`); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO health_probe (v) VALUES (1)`); err != nil {
+		return err
+	}
+	var v int
+	return tx.QueryRowContext(ctx, `SELECT v FROM health_probe`).Scan(&v)
+}
+
+// checkUpstream memastikan generator bisa dijangkau dengan HEAD ke origin-nya.
+// Status HTTP apa pun dianggap reachable; tidak ada generate yang dipicu.
+func checkUpstream(ctx context.Context) error {
+	u, err := url.Parse(itineraryServiceURL)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, u.Scheme+"://"+u.Host+"/", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := upstreamClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// internalHealthOnly membatasi endpoint health internal: butuh header X-Health-Token yang cocok
+// dengan token (tanpa token endpoint dianggap tidak ada), dan dibatasi satu kali per minInterval
+func internalHealthOnly(token string, minInterval time.Duration) gin.HandlerFunc {
+	var (
+		mu      sync.Mutex
+		lastRun time.Time
+	)
+	return func(c *gin.Context) {
+		if token == "" || subtle.ConstantTimeCompare([]byte(c.GetHeader("X-Health-Token")), []byte(token)) != 1 {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+
+		mu.Lock()
+		if time.Since(lastRun) < minInterval {
+			mu.Unlock()
+			respondError(c, http.StatusTooManyRequests, "rate_limited")
+			return
+		}
+		lastRun = time.Now()
+		mu.Unlock()
+		c.Next()
+	}
+}
+
+// overallStatus: "ok" jika semua check ok, selain itu "degraded" dengan 503
+func overallStatus(checks map[string]checkResult) (string, int) {
+	for _, res := range checks {
+		if res.Status != "ok" {
+			return "degraded", http.StatusServiceUnavailable
+		}
+	}
+	return "ok", http.StatusOK
+}
+
+// deepHealthHandler (GET /health/deep) menjalankan DB write+read roundtrip dan panggilan kecil
+// ke generator, lalu mengembalikan durasi masing-masing. Dipasang di belakang internalHealthOnly.
+func deepHealthHandler(c *gin.Context) {
+	checks := map[string]checkResult{
+		"db":       runCheck(c, 5*time.Second, func(ctx context.Context) error { return checkDBRoundtrip(ctx, db) }),
+		"upstream": runCheck(c, 5*time.Second, checkUpstream),
+	}
+	status, code := overallStatus(checks)
+	c.JSON(code, gin.H{"status": status, "checks": checks, "upstream_breaker": upstreamBreaker.currentState()})
+}
+
+// dependenciesHandler (GET /health/dependencies) mengecek semua dependency secara paralel,
+// masing-masing dengan timeout sendiri: ping DB primary, replica (jika berbeda), dan generator.
+// Status keseluruhan mengikuti komponen terburuk. Dipasang di belakang internalHealthOnly.
+func dependenciesHandler(c *gin.Context) {
+	probes := map[string]func(ctx context.Context) error{
+		"db_primary": func(ctx context.Context) error { return db.PingContext(ctx) },
+		"upstream":   checkUpstream,
+	}
+	if dbReplica != db {
+		probes["db_replica"] = func(ctx context.Context) error { return dbReplica.PingContext(ctx) }
+	}
+
+	ctx := c.Request.Context()
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		checks = make(map[string]checkResult, len(probes))
+	)
+	for name, fn := range probes {
+		wg.Add(1)
+		go func(name string, fn func(ctx context.Context) error) {
+			defer wg.Done()
+			res := runCheck(ctx, 3*time.Second, fn)
+			mu.Lock()
+			checks[name] = res
+			mu.Unlock()
+		}(name, fn)
+	}
+	wg.Wait()
+
+	status, code := overallStatus(checks)
+	c.JSON(code, gin.H{"status": status, "checks": checks})
+}
@@ -1,41 +1,46 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log"
 	"net"
 	"net/http"
 	"os"
+	"runtime"
 	"strconv"
 	"strings"
+	"time"
 
 	"cloud.google.com/go/cloudsqlconn"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/stdlib"
 	_ "github.com/jackc/pgx/v5/stdlib"
-	"golang.org/x/crypto/bcrypt"
 )
 
 var db *sql.DB
 
-// initDB menginisialisasi koneksi via Cloud SQL Connector
-func initDB(ctx context.Context) *sql.DB {
+// dbReplica dipakai untuk query read-only; sama dengan db jika replica tidak dikonfigurasi
+var dbReplica *sql.DB
+
+// initDB menginisialisasi koneksi via Cloud SQL Connector ke instance yang namanya
+// dibaca dari env instanceEnv (INSTANCE_CONNECTION_NAME untuk primary)
+func initDB(ctx context.Context, instanceEnv string) *sql.DB {
 	// Ambil env vars
-	dbUser := os.Getenv("DB_USER")                        // e.g. "postgres"
-	dbPass := os.Getenv("DB_PASS")                        // DB password
-	dbName := os.Getenv("DB_NAME")                        // e.g. "sps_db"
-	instanceConn := os.Getenv("INSTANCE_CONNECTION_NAME") // e.g. "project:region:instance"
-	usePrivate := os.Getenv("PRIVATE_IP")                 // "true" jika pakai private IP (opsional)
+	dbUser := os.Getenv("DB_USER")         // e.g. "postgres"
+	dbPass := os.Getenv("DB_PASS")         // DB password
+	dbName := os.Getenv("DB_NAME")         // e.g. "sps_db"
+	instanceConn := os.Getenv(instanceEnv) // e.g. "project:region:instance"
+	usePrivate := os.Getenv("PRIVATE_IP")  // "true" jika pakai private IP (opsional)
 
 	// Validasi
 	if dbUser == "" || dbPass == "" || dbName == "" || instanceConn == "" {
-		log.Fatal("Env vars DB_USER, DB_PASS, DB_NAME, INSTANCE_CONNECTION_NAME must be set")
+		log.Fatalf("Env vars DB_USER, DB_PASS, DB_NAME, %s must be set", instanceEnv)
 	}
 
 	// Build DSN dasar untuk pgx
@@ -76,7 +81,10 @@ func initDB(ctx context.Context) *sql.DB {
 		log.Fatalf("db.Ping: %v", err)
 	}
 
-	log.Println("✔️ Connected to Cloud SQL via Connector")
+	log.Printf("✔️ Connected to Cloud SQL via Connector (%s)", instanceEnv)
+
+	// Warmup pool (opsional)
+	warmupDB(ctx, db, envInt("DB_WARMUP_CONNS", 0))
 	return db
 }
 
@@ -85,9 +93,58 @@ func main() {
 	ctx := context.Background()
 
 	// Inisialisasi DB
-	db = initDB(ctx)
+	db = initDB(ctx, "INSTANCE_CONNECTION_NAME")
 	defer db.Close()
 
+	// Pantau kesehatan DB di background untuk guard requireDB
+	startDBPinger(ctx, db, envDuration("DB_PING_INTERVAL", 15*time.Second))
+
+	// Read replica (opsional); tanpa replica semua query ke primary
+	dbReplica = db
+	if os.Getenv("DB_REPLICA_INSTANCE_CONNECTION_NAME") != "" {
+		dbReplica = initDB(ctx, "DB_REPLICA_INSTANCE_CONNECTION_NAME")
+		defer dbReplica.Close()
+	}
+
+	// Ambang batas log slow query
+	slowQueryThreshold = time.Duration(envInt("SLOW_QUERY_MS", 500)) * time.Millisecond
+
+	// Timeout query DB per jenis operasi
+	dbReadTimeout = envDuration("DB_READ_TIMEOUT", dbReadTimeout)
+	dbWriteTimeout = envDuration("DB_WRITE_TIMEOUT", dbWriteTimeout)
+
+	// Algoritma hash untuk password baru (bcrypt default, atau argon2id)
+	hasher, err := newPasswordHasher(os.Getenv("PASSWORD_HASHER"))
+	if err != nil {
+		log.Fatalf("PASSWORD_HASHER: %v", err)
+	}
+	passwordHasher = hasher
+	initHashConcurrency(envInt("PASSWORD_HASH_CONCURRENCY", runtime.GOMAXPROCS(0)))
+	if err := initDummyPasswordHash(); err != nil {
+		log.Fatalf("init dummy password hash: %v", err)
+	}
+
+	// Daftar password yang ditolak (embed, atau BANNED_PASSWORDS_FILE)
+	banned, err := loadBannedPasswords(os.Getenv("BANNED_PASSWORDS_FILE"))
+	if err != nil {
+		log.Fatalf("banned passwords: %v", err)
+	}
+	bannedPasswords = banned
+
+	// Key enkripsi kolom sensitif (opsional)
+	keys, err := loadDataKeys()
+	if err != nil {
+		log.Fatalf("data encryption keys: %v", err)
+	}
+	dataKeys = keys
+
+	// Client bersama untuk itinerary generator
+	upstream = loadUpstreamConfig()
+	upstreamClient = newUpstreamClient(upstream)
+	upstreamBreaker = newCircuitBreaker("itinerary generator",
+		envInt("UPSTREAM_BREAKER_FAILURES", 5), envDuration("UPSTREAM_BREAKER_COOLDOWN", 30*time.Second))
+	generationSLO = time.Duration(envInt("GENERATION_SLO_MS", 0)) * time.Millisecond
+
 	// Set Gin mode
 	if os.Getenv("GIN_MODE") == "release" {
 		gin.SetMode(gin.ReleaseMode)
@@ -95,11 +152,45 @@ func main() {
 
 	r := gin.Default()
 
-	// CORS middleware
+	// Pakai context request (deadline/cancel) saat gin.Context dipakai sebagai context.Context
+	r.ContextWithFallback = true
+
+	// Trusted proxies untuk c.ClientIP(): daftar IP/CIDR dipisah koma, e.g. "35.191.0.0/16,130.211.0.0/22"
+	if proxies := os.Getenv("TRUSTED_PROXIES"); proxies != "" {
+		var trusted []string
+		for _, p := range strings.Split(proxies, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				trusted = append(trusted, p)
+			}
+		}
+		if err := r.SetTrustedProxies(trusted); err != nil {
+			log.Fatalf("invalid TRUSTED_PROXIES: %v", err)
+		}
+	} else {
+		log.Println("⚠️ TRUSTED_PROXIES not set, trusting X-Forwarded-For from all proxies")
+	}
+
+	// Validator custom (date_ymd, dst.)
+	preferenceKeys = parsePreferenceKeys(os.Getenv("PREFERENCE_KEYS"))
+	registerValidators()
+
+	// Request id: pakai dari client/proxy atau buat baru (nama header via REQUEST_ID_HEADER)
+	requestIDHeader := os.Getenv("REQUEST_ID_HEADER")
+	if requestIDHeader == "" {
+		requestIDHeader = "X-Request-ID"
+	}
+	r.Use(requestIDMiddleware(requestIDHeader))
+
+	// Security headers (CSP bisa diganti via CONTENT_SECURITY_POLICY)
+	r.Use(securityHeaders(os.Getenv("CONTENT_SECURITY_POLICY")))
+
+	// CORS middleware; Allow-Methods mengikuti method yang benar-benar terdaftar untuk path tsb
+	methodsFor := newRouteMethods(r)
 	r.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", methodsFor(c.Request.URL.Path))
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Encoding, Authorization, X-Envelope, Accept-Language, "+requestIDHeader)
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "X-Upstream-Request-ID, X-Itinerary-Warnings, "+requestIDHeader)
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
 			return
@@ -107,14 +198,35 @@ func main() {
 		c.Next()
 	})
 
+	// Maintenance mode: semua endpoint selain /health jadi 503
+	if os.Getenv("MAINTENANCE_MODE") == "true" {
+		retryAfter := os.Getenv("MAINTENANCE_RETRY_AFTER")
+		if retryAfter == "" {
+			retryAfter = "300"
+		}
+		log.Println("🚧 Maintenance mode enabled")
+		r.Use(maintenanceMode(retryAfter))
+	}
+
+	// Tolak client yang tidak menerima JSON (opsional, default off)
+	if os.Getenv("REQUIRE_JSON_ACCEPT") == "true" {
+		r.Use(requireJSONAccept())
+	}
+
+	// Response envelope opsional: selalu (RESPONSE_ENVELOPE=true) atau per request (X-Envelope: true)
+	r.Use(envelopeMiddleware(os.Getenv("RESPONSE_ENVELOPE") == "true"))
+
+	// Body request ber-gzip di-ekstrak sebelum binding (batas hasil ekstrak via MAX_DECOMPRESSED_BODY_BYTES)
+	r.Use(decompressRequest(int64(envInt("MAX_DECOMPRESSED_BODY_BYTES", 1<<20))))
+
 	// JSON parser endpoint: terima itinerary_markdown dan kembalikan JSON murni
 	r.POST("/jsonparser", func(c *gin.Context) {
 		// Tangkap input
 		var req struct {
 			ItineraryMarkdown string `json:"itinerary_markdown"`
 		}
-		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		if err := bindStrictJSON(c, &req); err != nil {
+			respondBindError(c, err)
 			return
 		}
 
@@ -134,7 +246,7 @@ func main() {
 		// Parse ke struktur Go
 		var parsed []map[string]interface{}
 		if err := json.Unmarshal([]byte(unquoted), &parsed); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "failed to parse JSON: " + err.Error()})
+			respondErrorWith(c, http.StatusBadRequest, "json_parse_failed", gin.H{"detail": err.Error()})
 			return
 		}
 
@@ -143,23 +255,64 @@ func main() {
 	})
 
 	// Routes
+	// Root: descriptor singkat service (SERVICE_NAME, SERVICE_VERSION, DOCS_URL), dibuat sekali saat startup
+	serviceName := os.Getenv("SERVICE_NAME")
+	if serviceName == "" {
+		serviceName = "backend"
+	}
+	serviceVersion := os.Getenv("SERVICE_VERSION")
+	if serviceVersion == "" {
+		serviceVersion = os.Getenv("K_REVISION") // di-set otomatis oleh Cloud Run
+	}
+	rootInfo := gin.H{"service": serviceName, "status": "ok"}
+	if serviceVersion != "" {
+		rootInfo["version"] = serviceVersion
+	}
+	if docsURL := os.Getenv("DOCS_URL"); docsURL != "" {
+		rootInfo["docs_url"] = docsURL
+	}
 	r.GET("/", func(c *gin.Context) {
-		c.String(http.StatusOK, "ok")
+		c.JSON(http.StatusOK, rootInfo)
 	})
 	r.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"status": "ok"})
 	})
-	r.POST("/signup", signupHandler)
-	r.POST("/signin", signinHandler)
-	r.POST("/itinerary", handleItineraryRequest)
+	// Health internal (butuh X-Health-Token), masing-masing dengan rate limit sendiri
+	healthToken := os.Getenv("DEEP_HEALTH_TOKEN")
+	healthInterval := envDuration("DEEP_HEALTH_MIN_INTERVAL", 10*time.Second)
+	r.GET("/health/deep", internalHealthOnly(healthToken, healthInterval), deepHealthHandler)
+	r.GET("/health/dependencies", internalHealthOnly(healthToken, healthInterval), dependenciesHandler)
+
+	// Auth: timeout pendek
+	auth := r.Group("", requireDB(), timeoutMiddleware(envDuration("AUTH_TIMEOUT", 10*time.Second)))
+	auth.POST("/signup", signupHandler)
+	auth.POST("/signin", signinHandler)
+
+	// Itinerary: generator bisa lama, timeout panjang. Jumlah generate bersamaan bisa dibatasi
+	// via MAX_CONCURRENT_GENERATIONS (default tanpa batas).
+	itinerary := r.Group("")
+	if n := envInt("MAX_CONCURRENT_GENERATIONS", 0); n > 0 {
+		itinerary.Use(concurrencyLimit(n, envDuration("GENERATION_QUEUE_WAIT", 2*time.Second)))
+	}
+	itinerary.Use(timeoutMiddleware(envDuration("ITINERARY_TIMEOUT", 120*time.Second)))
+	itinerary.POST("/itinerary", handleItineraryRequest)
+
+	// Opsi request itinerary untuk form di frontend; tidak lewat limit generate
+	r.GET("/itinerary/options", itineraryOptionsHandler())
 
 	// Start server
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
+	// Batas waktu global untuk semua request; harus di atas ITINERARY_TIMEOUT + antrean generate
+	globalTimeout := envDuration("GLOBAL_REQUEST_TIMEOUT", 180*time.Second)
+	srv := &http.Server{
+		Addr:    ":" + port,
+		Handler: globalTimeoutHandler(r, globalTimeout),
+	}
 	log.Printf("🚀 Server listening on port %s", port)
-	if err := r.Run(":" + port); err != nil {
+	if err := srv.ListenAndServe(); err != nil {
 		log.Fatalf("Server error: %v", err)
 	}
 }
@@ -169,31 +322,46 @@ func signupHandler(c *gin.Context) {
 		Username string `json:"username"`
 		Password string `json:"password"`
 	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := bindStrictJSON(c, &req); err != nil {
+		respondBindError(c, err)
 		return
 	}
 	if req.Username == "" || req.Password == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "username and password required"})
+		respondError(c, http.StatusBadRequest, "credentials_required")
+		return
+	}
+
+	if code := validateUsername(req.Username); code != "" {
+		respondError(c, http.StatusBadRequest, code)
+		return
+	}
+	if code := validatePassword(req.Password); code != "" {
+		respondError(c, http.StatusBadRequest, code)
 		return
 	}
 
-	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	hash, err := hashPassword(c, req.Password)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to hash password"})
+		if abortIfClientGone(c, err) {
+			return
+		}
+		respondLoggedError(c, http.StatusInternalServerError, "hash_failed", fmt.Errorf("signup hash password: %w", err))
 		return
 	}
 
-	_, err = db.ExecContext(c, `
+	_, err = execContext(c, "signup insert user", `
 		INSERT INTO users (username, password)
 		VALUES ($1, $2)
-	`, req.Username, string(hash))
+	`, req.Username, hash)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save user"})
+		if abortIfClientGone(c, err) {
+			return
+		}
+		respondLoggedError(c, http.StatusInternalServerError, "user_save_failed", fmt.Errorf("signup insert user: %w", err))
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "user created"})
+	c.JSON(http.StatusCreated, gin.H{"message": "user created"})
 }
 
 func signinHandler(c *gin.Context) {
@@ -201,65 +369,167 @@ func signinHandler(c *gin.Context) {
 		Username string `json:"username"`
 		Password string `json:"password"`
 	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := bindStrictJSON(c, &req); err != nil {
+		respondBindError(c, err)
 		return
 	}
 	if req.Username == "" || req.Password == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "username and password required"})
+		respondError(c, http.StatusBadRequest, "credentials_required")
 		return
 	}
 
 	var storedHash string
-	err := db.QueryRowContext(c, `
+	err := queryRowContext(c, "signin select password", `
 		SELECT password FROM users WHERE username = $1
 	`, req.Username).Scan(&storedHash)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid username or password"})
+		if abortIfClientGone(c, err) {
+			return
+		}
+		// Tetap jalankan compare supaya waktu respons sama dengan password salah
+		simulatePasswordCompare(c, req.Password)
+
+		// User tidak ditemukan dan error DB sama-sama 401 ke client; error DB tetap di-log
+		if !errors.Is(err, sql.ErrNoRows) {
+			respondLoggedError(c, http.StatusUnauthorized, "invalid_credentials", fmt.Errorf("signin select password: %w", err))
+			return
+		}
+		respondError(c, http.StatusUnauthorized, "invalid_credentials")
 		return
 	}
 
-	if bcrypt.CompareHashAndPassword([]byte(storedHash), []byte(req.Password)) != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid username or password"})
+	if err := comparePassword(c, storedHash, req.Password); err != nil {
+		if abortIfClientGone(c, err) {
+			return
+		}
+		if !errors.Is(err, errPasswordMismatch) {
+			respondLoggedError(c, http.StatusUnauthorized, "invalid_credentials", fmt.Errorf("signin compare password: %w", err))
+			return
+		}
+		respondError(c, http.StatusUnauthorized, "invalid_credentials")
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "login success"})
 }
 
+// itineraryOptionsHandler (GET /itinerary/options) mengembalikan whitelist dan batas yang dipakai
+// validasi POST /itinerary, supaya frontend tidak perlu hardcode. Body dibuat sekali saat startup
+// karena hanya berubah saat deploy.
+func itineraryOptionsHandler() gin.HandlerFunc {
+	options := gin.H{
+		"date_format": "YYYY-MM-DD",
+		"preferences": gin.H{
+			"keys":       preferenceKeys,
+			"min_weight": minPreferenceWeight,
+			"max_weight": maxPreferenceWeight,
+		},
+	}
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=86400")
+		c.JSON(http.StatusOK, options)
+	}
+}
+
+// itineraryRequest berisi field itinerary yang divalidasi sebelum diteruskan ke upstream
+type itineraryRequest struct {
+	StartDate   string         `json:"start_date" binding:"omitempty,date_ymd"`
+	EndDate     string         `json:"end_date" binding:"omitempty,date_ymd"`
+	Preferences map[string]int `json:"preferences" binding:"omitempty,dive,keys,preference_key,endkeys,min=0,max=10"`
+}
+
 func handleItineraryRequest(c *gin.Context) {
+	// Body di-cache oleh ShouldBindBodyWith agar bisa divalidasi schema dan di-bind ulang
 	var requestBody map[string]interface{}
-	if err := c.ShouldBindJSON(&requestBody); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := c.ShouldBindBodyWith(&requestBody, binding.JSON); err != nil {
+		respondErrorWith(c, http.StatusBadRequest, "invalid_request", gin.H{"detail": err.Error()})
 		return
 	}
 
-	jsonData, err := json.Marshal(requestBody)
+	// Validasi terhadap JSON Schema lalu cek typed di bawah; semua pelanggaran dikembalikan sekaligus
+	violations, err := validateSchema(itinerarySchema, c.MustGet(gin.BodyBytesKey).([]byte))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error marshaling JSON"})
+		respondErrorWith(c, http.StatusBadRequest, "invalid_request", gin.H{"detail": err.Error()})
 		return
 	}
+	for i, v := range violations {
+		violations[i].Message = localizedMessage(c, v.Code)
+	}
 
-	req, err := http.NewRequest("POST", "https://gsc2025-sps-418414887688.us-central1.run.app/run", bytes.NewBuffer(jsonData))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating request"})
+	// Bind ke struct typed untuk dipakai di bawah; pelanggarannya digabung dengan pelanggaran schema.
+	// Error tipe JSON sudah dilaporkan schema, jadi hanya dikembalikan jika schema lolos.
+	var itReq itineraryRequest
+	if err := c.ShouldBindBodyWith(&itReq, binding.JSON); err != nil {
+		typed, ok := bindingViolations(c, err)
+		if !ok && len(violations) == 0 {
+			respondErrorWith(c, http.StatusBadRequest, "invalid_request", gin.H{"detail": err.Error()})
+			return
+		}
+		violations = append(violations, typed...)
+	} else if itReq.StartDate != "" && itReq.EndDate != "" {
+		start, _ := parseDate(itReq.StartDate)
+		end, _ := parseDate(itReq.EndDate)
+		if end.Before(start) {
+			violations = append(violations, newViolation(c, "end_date", "end_date_before_start_date"))
+		}
+	}
+	if len(violations) > 0 {
+		respondErrorWith(c, http.StatusBadRequest, "invalid_itinerary_request", gin.H{"errors": violations})
 		return
 	}
-	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	jsonData, err := json.Marshal(requestBody)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error sending request"})
+		respondLoggedError(c, http.StatusInternalServerError, "marshal_failed", fmt.Errorf("itinerary marshal request: %w", err))
 		return
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	start := time.Now()
+	result, err := generateItinerary(c.Request.Context(), jsonData)
+	checkGenerationSLO(requestID(c), time.Since(start))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading response"})
+		if abortIfClientGone(c, err) {
+			return
+		}
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) {
+			apiErr = &APIError{Status: http.StatusInternalServerError, Code: "upstream_unreachable", Err: err}
+		}
+		respondLoggedError(c, apiErr.Status, apiErr.Code, fmt.Errorf("itinerary generate: %w", err))
 		return
 	}
 
-	c.Data(resp.StatusCode, "application/json", body)
+	// Teruskan request id dari generator (header, atau field request_id di body) untuk cross-check log
+	if result.requestID != "" {
+		c.Header("X-Upstream-Request-ID", result.requestID)
+		if result.status >= 400 {
+			log.Printf("itinerary generator returned %d (upstream_request_id=%s)", result.status, result.requestID)
+		}
+	}
+
+	// Tambahkan warnings non-fatal ke response sukses
+	body := result.body
+	if warnings := validateWithWarnings(itReq, time.Now()); len(warnings) > 0 && result.status < 300 {
+		body = withWarnings(c, body, warnings)
+	}
+
+	c.Data(result.status, "application/json", body)
+}
+
+// withWarnings menambahkan field "warnings" ke body JSON object. Jika body bukan object,
+// body dibiarkan dan warnings dikirim lewat header X-Itinerary-Warnings (JSON array).
+func withWarnings(c *gin.Context, body []byte, warnings []string) []byte {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(body, &obj); err == nil && obj != nil {
+		if w, err := json.Marshal(warnings); err == nil {
+			obj["warnings"] = w
+			if out, err := json.Marshal(obj); err == nil {
+				return out
+			}
+		}
+	}
+	if w, err := json.Marshal(warnings); err == nil {
+		c.Header("X-Itinerary-Warnings", string(w))
+	}
+	return body
 }
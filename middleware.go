@@ -0,0 +1,470 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requireJSONAccept menolak request dengan 406 jika header Accept tidak mengizinkan
+// application/json. Accept kosong dianggap menerima apa saja.
+func requireJSONAccept() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		accept := c.GetHeader("Accept")
+		if accept == "" || acceptsJSON(accept) {
+			c.Next()
+			return
+		}
+		respondError(c, http.StatusNotAcceptable, "not_acceptable")
+	}
+}
+
+// acceptsJSON cek apakah salah satu media range di header Accept cocok dengan application/json
+func acceptsJSON(accept string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(fields[0]))
+		if mediaType != "application/json" && mediaType != "application/*" && mediaType != "*/*" {
+			continue
+		}
+		// q=0 artinya client eksplisit menolak tipe ini
+		rejected := false
+		for _, param := range fields[1:] {
+			k, v, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if !ok || strings.TrimSpace(k) != "q" {
+				continue
+			}
+			if q, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil && q == 0 {
+				rejected = true
+			}
+		}
+		if !rejected {
+			return true
+		}
+	}
+	return false
+}
+
+// maintenanceMode mengembalikan 503 untuk semua endpoint kecuali /health (untuk probe),
+// dengan Retry-After dalam detik
+func maintenanceMode(retryAfter string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.URL.Path == "/health" {
+			c.Next()
+			return
+		}
+		c.Header("Retry-After", retryAfter)
+		respondError(c, http.StatusServiceUnavailable, "maintenance")
+	}
+}
+
+// timeoutMiddleware memasang deadline d pada context request. Response handler di-buffer;
+// jika deadline terlewati, response dibuang dan diganti 503 {"error":"request timeout"}.
+// Handler yang memakai context request (DB, upstream) akan berhenti saat deadline tercapai.
+// Handler tetap berjalan sinkron di dalam c.Next(): handler yang mengabaikan context menahan
+// koneksi sampai selesai, baru 503 dikirim. Yang memotong handler macet seperti itu hanya
+// globalTimeoutHandler (http.TimeoutHandler di level server).
+func timeoutMiddleware(d time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), d)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		orig := c.Writer
+		buf := &bufferedWriter{ResponseWriter: orig, header: orig.Header().Clone()}
+		c.Writer = buf
+		// Dipulihkan lewat defer supaya saat handler panic, gin.Recovery menulis 500 ke writer asli
+		// (bukan ke buffer yang tidak pernah di-flush, yang berakhir sebagai 200 kosong)
+		defer func() { c.Writer = orig }()
+		c.Next()
+		c.Writer = orig
+
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			respondError(c, http.StatusServiceUnavailable, "request_timeout")
+			return
+		}
+		buf.flush()
+	}
+}
+
+// decompressRequest meng-ekstrak body request dengan Content-Encoding: gzip sebelum di-bind.
+// Hasil ekstrak dibatasi maxBytes supaya gzip bomb ditolak dengan 413 alih-alih menghabiskan memori;
+// gzip yang rusak ditolak dengan 400.
+func decompressRequest(maxBytes int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !strings.EqualFold(strings.TrimSpace(c.GetHeader("Content-Encoding")), "gzip") || c.Request.Body == nil {
+			c.Next()
+			return
+		}
+
+		gz, err := gzip.NewReader(c.Request.Body)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "invalid_gzip")
+			return
+		}
+		defer gz.Close()
+		body, err := io.ReadAll(io.LimitReader(gz, maxBytes+1))
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "invalid_gzip")
+			return
+		}
+		if int64(len(body)) > maxBytes {
+			respondError(c, http.StatusRequestEntityTooLarge, "request_too_large")
+			return
+		}
+
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		c.Request.ContentLength = int64(len(body))
+		c.Request.Header.Del("Content-Encoding")
+		c.Request.Header.Set("Content-Length", strconv.Itoa(len(body)))
+		c.Next()
+	}
+}
+
+// globalTimeoutHandler membungkus seluruh router dengan http.TimeoutHandler sebagai pengaman
+// terakhir di atas timeout per route: handler yang hang tetap berakhir dengan 503 JSON.
+func globalTimeoutHandler(h http.Handler, d time.Duration) http.Handler {
+	body, _ := json.Marshal(gin.H{"error": errorMessages[defaultLang]["request_timeout"], "code": "request_timeout"})
+	th := http.TimeoutHandler(h, d, string(body))
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		th.ServeHTTP(jsonTimeoutWriter{w}, req)
+	})
+}
+
+// jsonTimeoutWriter memberi Content-Type JSON pada 503 dari TimeoutHandler, yang tidak
+// men-set header apa pun saat timeout. Response dari handler sudah membawa Content-Type sendiri.
+type jsonTimeoutWriter struct {
+	http.ResponseWriter
+}
+
+func (w jsonTimeoutWriter) WriteHeader(code int) {
+	if code == http.StatusServiceUnavailable && w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// bufferedWriter menahan header dan body sampai handler selesai
+type bufferedWriter struct {
+	gin.ResponseWriter
+	header  http.Header
+	body    bytes.Buffer
+	status  int
+	written bool
+}
+
+func (w *bufferedWriter) Header() http.Header { return w.header }
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	if !w.written && code > 0 {
+		w.status = code
+	}
+}
+
+func (w *bufferedWriter) WriteHeaderNow() { w.written = true }
+
+func (w *bufferedWriter) Write(b []byte) (int, error) {
+	w.written = true
+	return w.body.Write(b)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	w.written = true
+	return w.body.WriteString(s)
+}
+
+func (w *bufferedWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func (w *bufferedWriter) Size() int {
+	if !w.written {
+		return -1
+	}
+	return w.body.Len()
+}
+
+func (w *bufferedWriter) Written() bool { return w.written }
+
+// flush menyalin response yang di-buffer ke writer asli
+func (w *bufferedWriter) flush() {
+	dst := w.ResponseWriter.Header()
+	for k := range dst {
+		delete(dst, k)
+	}
+	for k, v := range w.header {
+		dst[k] = v
+	}
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	if w.body.Len() > 0 {
+		_, _ = w.ResponseWriter.Write(w.body.Bytes())
+	} else if w.written {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+// envelopeMetaKey: key gin.Context untuk meta envelope (mis. pagination) yang di-set handler
+const envelopeMetaKey = "envelope_meta"
+
+// envelopeMiddleware membungkus response JSON menjadi {"data": ..., "error": ..., "meta": {...}}.
+// Aktif untuk semua request jika always, atau per request dengan header "X-Envelope: true".
+// Response non-JSON atau tanpa body (mis. 404 /health/deep tanpa token) dibiarkan apa adanya.
+func envelopeMiddleware(always bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Vary", "X-Envelope")
+		if !always && c.GetHeader("X-Envelope") != "true" {
+			c.Next()
+			return
+		}
+
+		orig := c.Writer
+		buf := &bufferedWriter{ResponseWriter: orig, header: orig.Header().Clone()}
+		c.Writer = buf
+		// Sama seperti timeoutMiddleware: panic tidak boleh meninggalkan buffer sebagai writer
+		defer func() { c.Writer = orig }()
+		c.Next()
+		c.Writer = orig
+
+		if buf.body.Len() > 0 && strings.HasPrefix(buf.header.Get("Content-Type"), "application/json") {
+			if wrapped, err := wrapEnvelope(buf.Status(), buf.body.Bytes(), c.Value(envelopeMetaKey)); err == nil {
+				buf.body.Reset()
+				buf.body.Write(wrapped)
+				buf.header.Set("Content-Type", "application/json; charset=utf-8")
+				buf.header.Del("Content-Length")
+			}
+		}
+		buf.flush()
+	}
+}
+
+// wrapEnvelope menyusun body envelope; untuk status error, seluruh body error handler
+// ({"error","code",...} termasuk "errors"/"detail"/"field") menjadi envelope.error
+func wrapEnvelope(status int, body []byte, meta interface{}) ([]byte, error) {
+	var raw json.RawMessage
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, err
+	}
+	if meta == nil {
+		meta = gin.H{}
+	}
+
+	env := struct {
+		Data  interface{} `json:"data"`
+		Error interface{} `json:"error"`
+		Meta  interface{} `json:"meta"`
+	}{Meta: meta}
+
+	if status < http.StatusBadRequest {
+		env.Data = raw
+	} else {
+		env.Error = raw
+	}
+	return json.Marshal(env)
+}
+
+// newRouteMethods mengembalikan fungsi yang memberi daftar method terdaftar untuk sebuah path
+// (untuk Access-Control-Allow-Methods). Tabel dibangun dari r.Routes() saat pertama dipanggil,
+// jadi semua route sudah terdaftar ketika request pertama masuk.
+func newRouteMethods(r *gin.Engine) func(path string) string {
+	type route struct {
+		segments []string
+		methods  []string
+	}
+	var (
+		once   sync.Once
+		routes []*route
+	)
+	build := func() {
+		byPath := map[string]*route{}
+		for _, ri := range r.Routes() {
+			rt, ok := byPath[ri.Path]
+			if !ok {
+				rt = &route{segments: strings.Split(strings.Trim(ri.Path, "/"), "/")}
+				byPath[ri.Path] = rt
+				routes = append(routes, rt)
+			}
+			rt.methods = append(rt.methods, ri.Method)
+		}
+	}
+
+	return func(path string) string {
+		once.Do(build)
+		segments := strings.Split(strings.Trim(path, "/"), "/")
+		seen := map[string]bool{http.MethodOptions: true}
+		methods := []string{}
+		for _, rt := range routes {
+			if !matchRoute(rt.segments, segments) {
+				continue
+			}
+			for _, m := range rt.methods {
+				if !seen[m] {
+					seen[m] = true
+					methods = append(methods, m)
+				}
+			}
+		}
+		sort.Strings(methods)
+		return strings.Join(append(methods, http.MethodOptions), ", ")
+	}
+}
+
+// matchRoute mencocokkan segmen path dengan pola route Gin (":param" satu segmen, "*wildcard" sisanya)
+func matchRoute(pattern, path []string) bool {
+	for i, seg := range pattern {
+		if strings.HasPrefix(seg, "*") {
+			return true
+		}
+		if i >= len(path) {
+			return false
+		}
+		if !strings.HasPrefix(seg, ":") && seg != path[i] {
+			return false
+		}
+	}
+	return len(pattern) == len(path)
+}
+
+// concurrencyLimit membatasi jumlah request yang diproses bersamaan menjadi n. Request berikutnya
+// menunggu slot paling lama wait, lalu mendapat 503 dengan Retry-After.
+func concurrencyLimit(n int, wait time.Duration) gin.HandlerFunc {
+	sem := make(chan struct{}, n)
+	return func(c *gin.Context) {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case sem <- struct{}{}:
+			defer func() { <-sem }()
+			c.Next()
+		case <-timer.C:
+			c.Header("Retry-After", "5")
+			respondError(c, http.StatusServiceUnavailable, "too_busy")
+		case <-c.Request.Context().Done():
+			c.Abort()
+		}
+	}
+}
+
+// requireDB mengembalikan 503 untuk route yang butuh DB saat db belum terhubung
+// atau pinger melaporkan DB tidak sehat, alih-alih panic / menunggu timeout
+func requireDB() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if db == nil || !dbHealthy.Load() {
+			respondError(c, http.StatusServiceUnavailable, "database_unavailable")
+			return
+		}
+		c.Next()
+	}
+}
+
+// defaultCSP cocok untuk API JSON: tidak ada resource yang boleh dimuat dan tidak boleh di-frame
+const defaultCSP = "default-src 'none'; frame-ancestors 'none'"
+
+// securityHeaders men-set header hardening di semua response. csp kosong berarti defaultCSP.
+func securityHeaders(csp string) gin.HandlerFunc {
+	if csp == "" {
+		csp = defaultCSP
+	}
+	return func(c *gin.Context) {
+		h := c.Writer.Header()
+		h.Set("Strict-Transport-Security", "max-age=63072000; includeSubDomains")
+		h.Set("X-Content-Type-Options", "nosniff")
+		h.Set("X-Frame-Options", "DENY")
+		h.Set("Referrer-Policy", "no-referrer")
+		h.Set("Content-Security-Policy", csp)
+		c.Next()
+	}
+}
+
+// Key gin.Context untuk request id dan trace id (diisi requestIDMiddleware)
+const (
+	requestIDKey = "request_id"
+	traceIDKey   = "trace_id"
+)
+
+// cloudTraceHeader diisi load balancer / Cloud Run dengan format "TRACE_ID/SPAN_ID;o=1"
+const cloudTraceHeader = "X-Cloud-Trace-Context"
+
+// requestIDMiddleware memakai request id dari header (nama configurable, default X-Request-ID)
+// atau membuat yang baru, lalu mengembalikannya di header yang sama. Trace id dari
+// X-Cloud-Trace-Context ikut disimpan untuk log. Jika header yang dipakai adalah
+// X-Cloud-Trace-Context, request id = trace id-nya, dan header itu tidak pernah ditimpa
+// (nilai lain tidak sesuai format trace).
+func requestIDMiddleware(header string) gin.HandlerFunc {
+	useTrace := strings.EqualFold(header, cloudTraceHeader)
+	return func(c *gin.Context) {
+		trace := parseTraceID(c.GetHeader(cloudTraceHeader))
+		if trace != "" {
+			c.Set(traceIDKey, trace)
+		}
+
+		if useTrace {
+			id := trace
+			if id == "" {
+				id = newRequestID()
+			}
+			c.Set(requestIDKey, id)
+			c.Next()
+			return
+		}
+
+		id := c.GetHeader(header)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		c.Set(requestIDKey, id)
+		c.Header(header, id)
+		c.Next()
+	}
+}
+
+// parseTraceID mengambil TRACE_ID (32 hex) dari nilai X-Cloud-Trace-Context, atau "" jika tidak valid
+func parseTraceID(v string) string {
+	trace, _, _ := strings.Cut(v, "/")
+	if len(trace) != 32 {
+		return ""
+	}
+	if _, err := hex.DecodeString(trace); err != nil {
+		return ""
+	}
+	return trace
+}
+
+// validRequestID menerima id dari client hanya jika pendek dan berisi karakter aman untuk log
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID membuat id acak 16 byte dalam hex
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b)
+}
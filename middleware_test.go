@@ -0,0 +1,58 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// serve menjalankan satu request ke r dan mengembalikan recorder-nya
+func serve(r http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// quietRecovery seperti gin.Recovery (500 tanpa body) tapi tanpa mencetak stack trace
+func quietRecovery() gin.HandlerFunc {
+	return gin.RecoveryWithWriter(io.Discard)
+}
+
+func TestTimeoutMiddlewarePanicReturns500(t *testing.T) {
+	r := gin.New()
+	r.Use(quietRecovery())
+	r.GET("/panic", timeoutMiddleware(time.Second), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "login success"})
+		panic("boom")
+	})
+
+	w := serve(r, httptest.NewRequest(http.MethodGet, "/panic", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", w.Code)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("body = %q, buffered handler output must be discarded", w.Body.String())
+	}
+}
+
+func TestEnvelopeMiddlewarePanicReturns500(t *testing.T) {
+	for _, always := range []bool{false, true} {
+		r := gin.New()
+		r.Use(quietRecovery(), envelopeMiddleware(always))
+		r.GET("/panic", func(c *gin.Context) { panic("boom") })
+
+		req := httptest.NewRequest(http.MethodGet, "/panic", nil)
+		req.Header.Set("X-Envelope", "true")
+		if w := serve(r, req); w.Code != http.StatusInternalServerError {
+			t.Errorf("always=%v: status = %d, want 500", always, w.Code)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// errPasswordMismatch dikembalikan Compare jika password tidak cocok dengan hash
+var errPasswordMismatch = errors.New("password does not match")
+
+// PasswordHasher meng-hash password untuk disimpan dan membandingkan password dengan hash.
+// Hash yang dihasilkan selalu membawa identifier algoritma (prefix "$2a$", "$argon2id$", ...).
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Compare(hash, password string) error
+}
+
+// passwordHasher dipakai untuk hash baru; dipilih via PASSWORD_HASHER saat startup
+var passwordHasher PasswordHasher = bcryptHasher{cost: bcrypt.DefaultCost}
+
+// newPasswordHasher memilih implementasi berdasarkan nama ("bcrypt" default, atau "argon2id")
+func newPasswordHasher(name string) (PasswordHasher, error) {
+	switch strings.ToLower(name) {
+	case "", "bcrypt":
+		return bcryptHasher{cost: bcrypt.DefaultCost}, nil
+	case "argon2id":
+		return defaultArgon2id, nil
+	default:
+		return nil, fmt.Errorf("unknown password hasher %q", name)
+	}
+}
+
+// hashSlots membatasi jumlah hash/compare password yang berjalan bersamaan supaya lonjakan
+// signup/signin tidak memakan semua core; request lain menunggu giliran. nil berarti tanpa batas.
+var hashSlots chan struct{}
+
+// initHashConcurrency mengatur batas hashSlots (PASSWORD_HASH_CONCURRENCY, default GOMAXPROCS)
+func initHashConcurrency(n int) {
+	if n > 0 {
+		hashSlots = make(chan struct{}, n)
+	}
+}
+
+// withHashSlot menjalankan fn setelah mendapat slot, atau mengembalikan ctx.Err() jika
+// request selesai/timeout selama menunggu
+func withHashSlot(ctx context.Context, fn func() error) error {
+	if hashSlots == nil {
+		return fn()
+	}
+	select {
+	case hashSlots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-hashSlots }()
+	return fn()
+}
+
+// hashPassword meng-hash password dengan passwordHasher aktif, dibatasi hashSlots
+func hashPassword(ctx context.Context, password string) (string, error) {
+	var hash string
+	err := withHashSlot(ctx, func() error {
+		var err error
+		hash, err = passwordHasher.Hash(password)
+		return err
+	})
+	return hash, err
+}
+
+// dummyPasswordHash adalah hash dari password acak, dipakai untuk menyamakan waktu respons
+// signin saat username tidak ditemukan. Di-set ulang via initDummyPasswordHash jika hasher diganti.
+var dummyPasswordHash string
+
+// initDummyPasswordHash membuat dummyPasswordHash dengan passwordHasher yang aktif,
+// supaya biaya compare-nya sama dengan hash user sungguhan
+func initDummyPasswordHash() error {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return err
+	}
+	hash, err := passwordHasher.Hash(base64.RawStdEncoding.EncodeToString(buf))
+	if err != nil {
+		return err
+	}
+	dummyPasswordHash = hash
+	return nil
+}
+
+// simulatePasswordCompare menjalankan compare terhadap dummy hash dan membuang hasilnya,
+// supaya username yang tidak ada tidak bisa dibedakan dari password salah lewat timing
+func simulatePasswordCompare(ctx context.Context, password string) {
+	if dummyPasswordHash != "" {
+		_ = comparePassword(ctx, dummyPasswordHash, password)
+	}
+}
+
+// comparePassword membandingkan password dengan hash tersimpan memakai algoritma
+// yang tertera di hash, jadi hash bcrypt lama tetap bisa diverifikasi setelah ganti hasher.
+// Dibatasi hashSlots seperti hashPassword.
+func comparePassword(ctx context.Context, hash, password string) error {
+	return withHashSlot(ctx, func() error {
+		if strings.HasPrefix(hash, "$argon2id$") {
+			return defaultArgon2id.Compare(hash, password)
+		}
+		return bcryptHasher{}.Compare(hash, password)
+	})
+}
+
+// bcryptHasher: format hash standar bcrypt ($2a$<cost>$...)
+type bcryptHasher struct {
+	cost int
+}
+
+func (h bcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func (bcryptHasher) Compare(hash, password string) error {
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return errPasswordMismatch
+		}
+		return err
+	}
+	return nil
+}
+
+// argon2idHasher: format PHC $argon2id$v=19$m=<KiB>,t=<iter>,p=<threads>$<salt>$<key>
+type argon2idHasher struct {
+	time    uint32
+	memory  uint32 // KiB
+	threads uint8
+	saltLen int
+	keyLen  uint32
+}
+
+// defaultArgon2id mengikuti rekomendasi kedua RFC 9106 (64 MiB, t=3, p=4)
+var defaultArgon2id = argon2idHasher{time: 3, memory: 64 * 1024, threads: 4, saltLen: 16, keyLen: 32}
+
+func (h argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.saltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.time, h.memory, h.threads, h.keyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.memory, h.time, h.threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// Compare memakai parameter yang tersimpan di hash, bukan parameter h
+func (argon2idHasher) Compare(hash, password string) error {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return errors.New("invalid argon2id hash")
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return errors.New("unsupported argon2id version")
+	}
+	var memory, iterations uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &iterations, &threads); err != nil {
+		return errors.New("invalid argon2id parameters")
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return errors.New("invalid argon2id salt")
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return errors.New("invalid argon2id key")
+	}
+
+	got := argon2.IDKey([]byte(password), salt, iterations, memory, threads, uint32(len(want)))
+	if subtle.ConstantTimeCompare(got, want) != 1 {
+		return errPasswordMismatch
+	}
+	return nil
+}
+
+// `go generate` mengisi data/banned_passwords.txt dengan top-10k password umum dari SecLists
+//
+//go:generate sh -c "{ echo '# Top 10k password umum (SecLists 10k-most-common.txt), ditolak saat signup. Dibuat ulang via go generate.'; curl -sSfL https://raw.githubusercontent.com/danielmiessler/SecLists/master/Passwords/Common-Credentials/10k-most-common.txt; } > data/banned_passwords.txt.tmp && mv data/banned_passwords.txt.tmp data/banned_passwords.txt"
+//go:embed data/banned_passwords.txt
+var defaultBannedPasswords []byte
+
+// bannedPasswords berisi password umum (lowercase) yang ditolak validatePassword
+var bannedPasswords = map[string]struct{}{}
+
+// loadBannedPasswords memuat daftar dari file path, atau daftar embed jika path kosong.
+// Format: satu password per baris, baris kosong dan diawali "#" diabaikan.
+func loadBannedPasswords(path string) (map[string]struct{}, error) {
+	var r io.Reader = bytes.NewReader(defaultBannedPasswords)
+	if path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	set := map[string]struct{}{}
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		set[strings.ToLower(line)] = struct{}{}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// validatePassword mengembalikan code error (lihat errorMessages) jika password ditolak, atau "" jika ok
+func validatePassword(password string) string {
+	if _, banned := bannedPasswords[strings.ToLower(password)]; banned {
+		return "password_too_common"
+	}
+	return ""
+}
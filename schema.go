@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+//go:embed schemas/*.json
+var schemaFS embed.FS
+
+// itinerarySchema memvalidasi body /itinerary sesuai kontrak generator (schemas/itinerary_request.json)
+var itinerarySchema = mustCompileSchema("schemas/itinerary_request.json")
+
+// mustCompileSchema meng-compile JSON Schema yang di-embed; keyword "format" ikut divalidasi jika dipakai
+func mustCompileSchema(path string) *jsonschema.Schema {
+	raw, err := schemaFS.ReadFile(path)
+	if err != nil {
+		log.Fatalf("read schema %s: %v", path, err)
+	}
+	c := jsonschema.NewCompiler()
+	c.AssertFormat = true
+	if err := c.AddResource(path, bytes.NewReader(raw)); err != nil {
+		log.Fatalf("load schema %s: %v", path, err)
+	}
+	s, err := c.Compile(path)
+	if err != nil {
+		log.Fatalf("compile schema %s: %v", path, err)
+	}
+	return s
+}
+
+// schemaViolation adalah satu pelanggaran validasi (schema atau cek typed); semua dikirim di "errors"
+type schemaViolation struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Detail  string `json:"detail,omitempty"` // pesan asli library schema (English)
+}
+
+// validateSchema memvalidasi body JSON terhadap schema dan mengembalikan semua pelanggaran sekaligus.
+// Error non-validasi (mis. JSON rusak) dikembalikan sebagai err.
+func validateSchema(s *jsonschema.Schema, body []byte) ([]schemaViolation, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	err := s.Validate(doc)
+	var ve *jsonschema.ValidationError
+	if !errors.As(err, &ve) {
+		return nil, err
+	}
+
+	// Ambil hanya error daun; error induk hanya merangkum penyebabnya
+	var out []schemaViolation
+	var walk func(*jsonschema.ValidationError)
+	walk = func(e *jsonschema.ValidationError) {
+		if len(e.Causes) == 0 {
+			field := strings.ReplaceAll(strings.TrimPrefix(e.InstanceLocation, "/"), "/", ".")
+			// Pesan library tidak terlokalisasi, jadi disimpan di Detail; Message diisi handler sesuai bahasa request
+			out = append(out, schemaViolation{Field: field, Code: "schema_violation", Detail: e.Message})
+			return
+		}
+		for _, cause := range e.Causes {
+			walk(cause)
+		}
+	}
+	walk(ve)
+	return out, nil
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// itineraryServiceURL adalah endpoint itinerary generator
+const itineraryServiceURL = "https://gsc2025-sps-418414887688.us-central1.run.app/run"
+
+// upstreamConfig berisi setting koneksi ke itinerary generator, dibaca dari env saat startup
+type upstreamConfig struct {
+	token               string // ITINERARY_SERVICE_TOKEN, tidak pernah di-log
+	authHeader          string // ITINERARY_SERVICE_AUTH_HEADER: "Authorization" (default) atau "X-API-Key"
+	maxIdleConns        int
+	maxIdleConnsPerHost int
+	idleConnTimeout     time.Duration
+	maxResponseBytes    int64 // batas ukuran body response generator
+}
+
+// loadUpstreamConfig membaca upstreamConfig dari env
+func loadUpstreamConfig() upstreamConfig {
+	return upstreamConfig{
+		token:               os.Getenv("ITINERARY_SERVICE_TOKEN"),
+		authHeader:          os.Getenv("ITINERARY_SERVICE_AUTH_HEADER"),
+		maxIdleConns:        envInt("UPSTREAM_MAX_IDLE_CONNS", 100),
+		maxIdleConnsPerHost: envInt("UPSTREAM_MAX_IDLE_CONNS_PER_HOST", 20),
+		idleConnTimeout:     envDuration("UPSTREAM_IDLE_CONN_TIMEOUT", 90*time.Second),
+		maxResponseBytes:    int64(envInt("UPSTREAM_MAX_RESPONSE_BYTES", 5<<20)),
+	}
+}
+
+// upstream adalah config aktif; upstreamClient dipakai bersama supaya koneksi TLS ke generator di-reuse
+var (
+	upstream       = upstreamConfig{}
+	upstreamClient = &http.Client{}
+)
+
+// newUpstreamClient membuat http.Client dengan transport yang di-tuning sesuai cfg
+func newUpstreamClient(cfg upstreamConfig) *http.Client {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.MaxIdleConns = cfg.maxIdleConns
+	t.MaxIdleConnsPerHost = cfg.maxIdleConnsPerHost
+	t.IdleConnTimeout = cfg.idleConnTimeout
+	return &http.Client{Transport: t}
+}
+
+// generationSLO adalah target durasi generate (GENERATION_SLO_MS); 0 menonaktifkan pengecekan.
+// generationSLOViolations menghitung generate yang melewatinya sejak proses start.
+var (
+	generationSLO           time.Duration
+	generationSLOViolations atomic.Int64
+)
+
+// checkGenerationSLO mencatat warning terstruktur (key=value) jika generate melewati generationSLO
+func checkGenerationSLO(requestID string, d time.Duration) {
+	if generationSLO <= 0 || d <= generationSLO {
+		return
+	}
+	n := generationSLOViolations.Add(1)
+	log.Printf("level=warn event=generation_slo_exceeded request_id=%s duration_ms=%d slo_ms=%d violations_total=%d",
+		requestID, d.Milliseconds(), generationSLO.Milliseconds(), n)
+}
+
+// upstreamBreaker melindungi generator saat sedang gagal (UPSTREAM_BREAKER_FAILURES,
+// UPSTREAM_BREAKER_COOLDOWN); di-set ulang saat startup
+var upstreamBreaker = newCircuitBreaker("itinerary generator", 0, 0)
+
+// generationGroup menggabungkan request identik yang sedang berjalan ke satu panggilan upstream
+var generationGroup singleflight.Group
+
+// upstreamResult adalah response generator yang sudah dibaca penuh
+type upstreamResult struct {
+	status    int
+	requestID string // request id internal generator, kosong jika tidak ada
+	body      []byte
+}
+
+// generateItinerary mengirim payload ke generator. Request dengan payload identik yang datang
+// bersamaan hanya memicu satu panggilan upstream dan berbagi hasilnya. singleflight tidak
+// menyimpan hasil setelah panggilan selesai, jadi error tidak ikut "di-cache".
+func generateItinerary(ctx context.Context, payload []byte) (*upstreamResult, error) {
+	sum := sha256.Sum256(payload)
+	key := hex.EncodeToString(sum[:])
+
+	ch := generationGroup.DoChan(key, func() (interface{}, error) {
+		// Jangan ikut batal saat client pertama disconnect (request lain mungkin ikut menunggu),
+		// tapi tetap hormati deadline-nya
+		callCtx := context.WithoutCancel(ctx)
+		if deadline, ok := ctx.Deadline(); ok {
+			var cancel context.CancelFunc
+			callCtx, cancel = context.WithDeadline(callCtx, deadline)
+			defer cancel()
+		}
+		if err := upstreamBreaker.allow(); err != nil {
+			return nil, &APIError{Status: http.StatusServiceUnavailable, Code: "upstream_unavailable", Err: err}
+		}
+		res, err := callItineraryService(callCtx, payload)
+		// Error transport, timeout, dan 5xx dihitung gagal; 4xx adalah kesalahan request
+		upstreamBreaker.record(err != nil || res.status >= 500)
+		return res, err
+	})
+
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.(*upstreamResult), nil
+	case <-ctx.Done():
+		return nil, &APIError{Status: http.StatusInternalServerError, Code: "upstream_unreachable", Err: ctx.Err()}
+	}
+}
+
+// callItineraryService melakukan satu panggilan POST ke generator
+func callItineraryService(ctx context.Context, payload []byte) (*upstreamResult, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", itineraryServiceURL, bytes.NewBuffer(payload))
+	if err != nil {
+		return nil, &APIError{Status: http.StatusInternalServerError, Code: "upstream_request_failed", Err: err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	// Auth ke generator (opsional). Default "Authorization: Bearer <token>",
+	// atau token mentah di X-API-Key
+	if upstream.token != "" {
+		if strings.EqualFold(upstream.authHeader, "X-API-Key") {
+			req.Header.Set("X-API-Key", upstream.token)
+		} else {
+			req.Header.Set("Authorization", "Bearer "+upstream.token)
+		}
+	}
+
+	resp, err := upstreamClient.Do(req)
+	if err != nil {
+		return nil, &APIError{Status: http.StatusInternalServerError, Code: "upstream_unreachable", Err: err}
+	}
+	defer resp.Body.Close()
+
+	// Baca maksimal maxResponseBytes+1 supaya body yang kebesaran bisa dideteksi tanpa memuat semuanya
+	body, err := io.ReadAll(io.LimitReader(resp.Body, upstream.maxResponseBytes+1))
+	if err != nil {
+		return nil, &APIError{Status: http.StatusInternalServerError, Code: "upstream_read_failed", Err: err}
+	}
+	if int64(len(body)) > upstream.maxResponseBytes {
+		log.Printf("itinerary generator response exceeded %d bytes (status %d), rejecting", upstream.maxResponseBytes, resp.StatusCode)
+		return nil, &APIError{Status: http.StatusBadGateway, Code: "upstream_response_too_large", Err: errors.New("upstream response too large")}
+	}
+
+	return &upstreamResult{
+		status:    resp.StatusCode,
+		requestID: upstreamRequestID(resp, body),
+		body:      body,
+	}, nil
+}
+
+// upstreamRequestID mengambil request id internal generator; kosong jika tidak ada
+func upstreamRequestID(resp *http.Response, body []byte) string {
+	if id := resp.Header.Get("X-Request-ID"); id != "" {
+		return id
+	}
+	var payload struct {
+		RequestID string `json:"request_id"`
+	}
+	if json.Unmarshal(body, &payload) == nil {
+		return payload.RequestID
+	}
+	return ""
+}
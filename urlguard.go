@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// blockedPrefixes adalah range yang tidak boleh dituju URL dari user, di luar
+// loopback/private/link-local yang sudah dicek lewat method netip.Addr
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // "this network"
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),  // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"), // benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),   // reserved
+	netip.MustParsePrefix("64:ff9b::/96"),  // NAT64, bisa memetakan ke IPv4 internal
+}
+
+// errBlockedAddress dikembalikan saat koneksi ke IP non-publik ditolak
+var errBlockedAddress = errors.New("url must not point to a private or internal address")
+
+// maxExternalRedirects membatasi redirect yang diikuti newExternalClient
+const maxExternalRedirects = 5
+
+// newExternalClient membuat http.Client untuk request ke URL dari user. IP dicek saat connect
+// (setelah DNS di-resolve oleh dialer), jadi DNS rebinding dan redirect ke host internal ikut
+// tertolak; proxy dari env dimatikan karena akan melewati pengecekan ini. Request ke URL dari
+// user wajib memakai client ini, validateExternalURL hanya pre-check untuk pesan error yang jelas.
+func newExternalClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: 10 * time.Second, Control: blockNonPublicDial}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = nil
+	t.DialContext = dialer.DialContext
+	return &http.Client{
+		Transport:     t,
+		Timeout:       timeout,
+		CheckRedirect: checkExternalRedirect,
+	}
+}
+
+// blockNonPublicDial adalah net.Dialer.Control: menolak koneksi ke alamat yang bukan IP publik.
+// address di sini sudah berupa IP hasil resolve, bukan hostname.
+func blockNonPublicDial(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return fmt.Errorf("dial %s: %w", address, err)
+	}
+	if !isPublicAddr(addr) {
+		return errBlockedAddress
+	}
+	return nil
+}
+
+// checkExternalRedirect hanya mengizinkan redirect ke http(s) tanpa credentials, maksimal
+// maxExternalRedirects kali; IP tujuan tetap dicek blockNonPublicDial saat connect
+func checkExternalRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxExternalRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxExternalRedirects)
+	}
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return errors.New("redirect scheme must be http or https")
+	}
+	if req.URL.User != nil {
+		return errors.New("redirect url must not contain credentials")
+	}
+	return nil
+}
+
+// validateExternalURL memastikan URL dari user hanya http(s) dan host-nya resolve ke IP publik,
+// supaya backend tidak bisa dipakai untuk request ke service internal atau metadata server GCP
+// (169.254.169.254). Hasil resolve bisa berubah sebelum request dikirim (DNS rebinding), jadi
+// ini hanya pre-check; request-nya sendiri harus lewat newExternalClient.
+func validateExternalURL(ctx context.Context, raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, errors.New("url scheme must be http or https")
+	}
+	if u.User != nil {
+		return nil, errors.New("url must not contain credentials")
+	}
+	host := u.Hostname()
+	if host == "" {
+		return nil, errors.New("url host is required")
+	}
+
+	// Host berupa IP literal tidak perlu di-resolve
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if !isPublicAddr(addr) {
+			return nil, errBlockedAddress
+		}
+		return u, nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", strings.TrimSuffix(host, "."))
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve url host: %w", err)
+	}
+	if len(addrs) == 0 {
+		return nil, errors.New("cannot resolve url host")
+	}
+	for _, addr := range addrs {
+		if !isPublicAddr(addr) {
+			return nil, errBlockedAddress
+		}
+	}
+	return u, nil
+}
+
+// isPublicAddr cek apakah addr bisa dituju dari internet publik
+func isPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsMulticast() ||
+		addr.IsInterfaceLocalMulticast() {
+		return false
+	}
+	for _, p := range blockedPrefixes {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
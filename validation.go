@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// dateLayout adalah satu-satunya format tanggal yang diterima (YYYY-MM-DD)
+const dateLayout = "2006-01-02"
+
+// dateShape dipakai untuk membedakan format salah vs tanggal yang tidak ada (mis. 2024-02-30)
+var dateShape = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+
+// Bobot preferensi aktivitas di itinerary request (0 = hindari, 10 = prioritaskan)
+const (
+	minPreferenceWeight = 0
+	maxPreferenceWeight = 10
+)
+
+// defaultPreferenceKeys dipakai jika PREFERENCE_KEYS tidak di-set
+var defaultPreferenceKeys = []string{"food", "museums", "nature", "nightlife", "shopping", "culture", "adventure", "relaxation"}
+
+// preferenceKeys adalah whitelist key preferences; di-set saat startup via parsePreferenceKeys
+var preferenceKeys = parsePreferenceKeys("")
+
+// parsePreferenceKeys mem-parsing daftar key dipisah koma (PREFERENCE_KEYS), atau default jika kosong
+func parsePreferenceKeys(raw string) []string {
+	if strings.TrimSpace(raw) == "" {
+		return defaultPreferenceKeys
+	}
+	var keys []string
+	for _, k := range strings.Split(raw, ",") {
+		if k = strings.ToLower(strings.TrimSpace(k)); k != "" {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// isPreferenceKey cek apakah key ada di whitelist preferenceKeys
+func isPreferenceKey(key string) bool {
+	for _, k := range preferenceKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// registerValidators mendaftarkan validator custom ke validator engine milik Gin,
+// sehingga tag `date_ymd` bisa dipakai di semua request struct
+func registerValidators() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		log.Fatal("gin validator engine is not go-playground/validator")
+	}
+
+	// Pakai nama field dari tag json supaya pesan error cocok dengan payload client
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+		if name == "-" || name == "" {
+			return f.Name
+		}
+		return name
+	})
+
+	if err := v.RegisterValidation("date_ymd", func(fl validator.FieldLevel) bool {
+		_, err := parseDate(fl.Field().String())
+		return err == nil
+	}); err != nil {
+		log.Fatalf("register date_ymd validator: %v", err)
+	}
+	if err := v.RegisterValidation("preference_key", func(fl validator.FieldLevel) bool {
+		return isPreferenceKey(fl.Field().String())
+	}); err != nil {
+		log.Fatalf("register preference_key validator: %v", err)
+	}
+}
+
+// Error parseDate, dipetakan ke code invalid_date_format / invalid_calendar_date
+var (
+	errDateFormat   = errors.New("must be YYYY-MM-DD")
+	errCalendarDate = errors.New("is not a valid calendar date")
+)
+
+// parseDate mem-parsing string YYYY-MM-DD ke time.Time (UTC)
+func parseDate(s string) (time.Time, error) {
+	if !dateShape.MatchString(s) {
+		return time.Time{}, errDateFormat
+	}
+	t, err := time.Parse(dateLayout, s)
+	if err != nil {
+		return time.Time{}, errCalendarDate
+	}
+	return t, nil
+}
+
+// Aturan username untuk akun baru
+const (
+	minUsernameLen = 3
+	maxUsernameLen = 30
+)
+
+// usernameChars: huruf, angka, underscore, titik, dan hyphen
+var usernameChars = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// reservedUsernames tidak boleh didaftarkan (case-insensitive) supaya tidak bisa dipakai menyamar
+var reservedUsernames = map[string]struct{}{"admin": {}, "root": {}, "support": {}}
+
+// validateUsername mengembalikan code error (lihat errorMessages) jika username ditolak, atau "" jika ok.
+// Hanya untuk username baru; user lama tetap bisa signin dengan username apa pun yang tersimpan.
+func validateUsername(username string) string {
+	switch {
+	case len(username) < minUsernameLen || len(username) > maxUsernameLen:
+		return "username_invalid_length"
+	case !usernameChars.MatchString(username):
+		return "username_invalid_chars"
+	case strings.ContainsAny(username[:1], "_.-") || strings.ContainsAny(username[len(username)-1:], "_.-"):
+		return "username_invalid_edge"
+	}
+	if _, reserved := reservedUsernames[strings.ToLower(username)]; reserved {
+		return "username_reserved"
+	}
+	return ""
+}
+
+// bindStrictJSON seperti c.ShouldBindJSON tapi menolak field yang tidak dikenal, supaya typo
+// di client tidak diam-diam diabaikan. Endpoint yang meneruskan JSON bebas (POST /itinerary)
+// tetap memakai binding biasa.
+func bindStrictJSON(c *gin.Context, obj interface{}) error {
+	if c.Request.Body == nil {
+		return errors.New("invalid request")
+	}
+	dec := json.NewDecoder(c.Request.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(obj); err != nil {
+		return err
+	}
+	return binding.Validator.ValidateStruct(obj)
+}
+
+// respondBindError mengirim 400 untuk error bindStrictJSON: unknown_field dengan nama field-nya,
+// atau invalid_request untuk error lain
+func respondBindError(c *gin.Context, err error) {
+	if name, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		if field, uerr := strconv.Unquote(name); uerr == nil {
+			respondErrorWith(c, http.StatusBadRequest, "unknown_field", gin.H{"field": field})
+			return
+		}
+	}
+	respondErrorWith(c, http.StatusBadRequest, "invalid_request", gin.H{"detail": err.Error()})
+}
+
+// bindingViolations mengubah error validasi binding menjadi daftar pelanggaran dengan format yang
+// sama dengan validateSchema (field map ditulis "preferences.food"); pesan mengikuti bahasa request.
+// ok false jika err bukan error validasi (mis. tipe JSON salah).
+func bindingViolations(c *gin.Context, err error) (out []schemaViolation, ok bool) {
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return nil, false
+	}
+	for _, fe := range verrs {
+		code := "invalid_field"
+		switch fe.Tag() {
+		case "required":
+			code = "field_required"
+		case "preference_key":
+			code = "unknown_preference"
+		case "min", "max":
+			code = "preference_weight_out_of_range"
+		case "date_ymd":
+			s, _ := fe.Value().(string)
+			code = "invalid_date_format"
+			if _, perr := parseDate(s); errors.Is(perr, errCalendarDate) {
+				code = "invalid_calendar_date"
+			}
+		}
+		field := strings.TrimSuffix(strings.Replace(fe.Field(), "[", ".", 1), "]")
+		out = append(out, newViolation(c, field, code))
+	}
+	return out, true
+}
+
+// newViolation membuat pelanggaran field dengan code dari errorMessages dan pesan terlokalisasi
+func newViolation(c *gin.Context, field, code string) schemaViolation {
+	return schemaViolation{Field: field, Code: code, Message: localizedMessage(c, code)}
+}
+
+// validateWithWarnings mengumpulkan catatan non-fatal untuk input yang valid tapi mencurigakan.
+// Request tetap diproses; catatan dikembalikan ke client sebagai "warnings".
+func validateWithWarnings(req itineraryRequest, now time.Time) []string {
+	var warnings []string
+	if req.StartDate == "" {
+		return warnings
+	}
+	start, err := parseDate(req.StartDate)
+	if err != nil {
+		return warnings
+	}
+
+	today := now.UTC().Truncate(24 * time.Hour)
+	if start.Before(today) {
+		warnings = append(warnings, "start_date is in the past")
+	}
+	if req.EndDate != "" {
+		if end, err := parseDate(req.EndDate); err == nil {
+			days := int(end.Sub(start).Hours()/24) + 1
+			switch {
+			case days < 2:
+				warnings = append(warnings, "trip length under 2 days may produce sparse results")
+			case days > 30:
+				warnings = append(warnings, "trip length over 30 days may produce a less detailed itinerary")
+			}
+		}
+	}
+	return warnings
+}